@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	"github.com/AdityaaMK/kubernetes-scraper/k8sclient"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// statefulSetClaims maintains StatefulSet -> PersistentVolumeClaim claims
+// relationships. The StatefulSet controller names each claim
+// <template>-<statefulset>-<ordinal> and keeps it after scaling down, so the
+// relationships are derived from the claims that exist rather than from the
+// replica count.
+type statefulSetClaims struct {
+	g         *graph.Graph
+	informers *k8sclient.Informers
+
+	// mu serializes recomputing StatefulSets from the two event handlers and resync
+	mu sync.Mutex
+}
+
+func newStatefulSetClaims(g *graph.Graph, informers *k8sclient.Informers) *statefulSetClaims {
+	return &statefulSetClaims{g: g, informers: informers}
+}
+
+// register adds the StatefulSet and PersistentVolumeClaim event handlers
+func (c *statefulSetClaims) register() error {
+	syncStatefulSet := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if sts, ok := obj.(*appsv1.StatefulSet); ok {
+			c.syncStatefulSet(sts.Namespace, sts.Name)
+		}
+	}
+	err := c.informers.AddEventHandler("StatefulSet", cache.ResourceEventHandlerFuncs{
+		AddFunc:    syncStatefulSet,
+		UpdateFunc: func(_, newObj interface{}) { syncStatefulSet(newObj) },
+		DeleteFunc: syncStatefulSet,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Claims are only ever created and deleted under their StatefulSet's naming scheme
+	syncClaim := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if claim, ok := obj.(*corev1.PersistentVolumeClaim); ok {
+			c.syncClaim(claim)
+		}
+	}
+	return c.informers.AddEventHandler("PersistentVolumeClaim", cache.ResourceEventHandlerFuncs{
+		AddFunc:    syncClaim,
+		DeleteFunc: syncClaim,
+	})
+}
+
+// resync recomputes every StatefulSet once the caches have synced
+func (c *statefulSetClaims) resync() {
+	for _, obj := range c.informers.Indexer("StatefulSet").List() {
+		if sts, ok := obj.(*appsv1.StatefulSet); ok {
+			c.syncStatefulSet(sts.Namespace, sts.Name)
+		}
+	}
+}
+
+// syncClaim recomputes the StatefulSets in a claim's namespace whose claims it could be
+func (c *statefulSetClaims) syncClaim(claim *corev1.PersistentVolumeClaim) {
+	objs, err := c.informers.Indexer("StatefulSet").ByIndex(cache.NamespaceIndex, claim.Namespace)
+	if err != nil {
+		log.Printf("Error listing StatefulSets for PersistentVolumeClaim %s/%s: %v", claim.Namespace, claim.Name, err)
+		return
+	}
+	for _, obj := range objs {
+		sts, ok := obj.(*appsv1.StatefulSet)
+		if !ok {
+			continue
+		}
+		for _, template := range sts.Spec.VolumeClaimTemplates {
+			if _, ok := claimOrdinal(claim.Name, template.Name, sts.Name); ok {
+				c.syncStatefulSet(sts.Namespace, sts.Name)
+				break
+			}
+		}
+	}
+}
+
+// syncStatefulSet recomputes the claims relationships of a StatefulSet from
+// the cached claims in its namespace, or releases them if it no longer exists
+func (c *statefulSetClaims) syncStatefulSet(namespace, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := graph.EntityKey{Name: name, Namespace: namespace, Type: "StatefulSet"}
+	origin := "claims:" + key.String()
+
+	obj, exists, err := c.informers.Indexer("StatefulSet").GetByKey(namespace + "/" + name)
+	if err != nil {
+		log.Printf("Error getting StatefulSet %s/%s: %v", namespace, name, err)
+		return
+	}
+	sts, ok := obj.(*appsv1.StatefulSet)
+	if !exists || !ok || len(sts.Spec.VolumeClaimTemplates) == 0 {
+		c.g.SyncRelationships(origin, nil)
+		return
+	}
+
+	objs, err := c.informers.Indexer("PersistentVolumeClaim").ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		log.Printf("Error listing PersistentVolumeClaims for StatefulSet %s/%s: %v", namespace, name, err)
+		return
+	}
+
+	var rels []graph.GraphRelationship
+	for _, obj := range objs {
+		claim, ok := obj.(*corev1.PersistentVolumeClaim)
+		if !ok {
+			continue
+		}
+		for _, template := range sts.Spec.VolumeClaimTemplates {
+			ordinal, ok := claimOrdinal(claim.Name, template.Name, sts.Name)
+			if !ok {
+				continue
+			}
+			rels = append(rels, graph.GraphRelationship{
+				Source:           key,
+				Target:           graph.EntityKey{Name: claim.Name, Namespace: namespace, Type: "PersistentVolumeClaim"},
+				RelationshipType: "claims",
+				Properties: map[string]string{
+					"template": template.Name,
+					"ordinal":  fmt.Sprintf("%d", ordinal),
+				},
+			})
+			break
+		}
+	}
+
+	c.g.SyncRelationships(origin, rels)
+}
+
+// claimOrdinal returns the ordinal of a claim named
+// <template>-<statefulset>-<ordinal>, or false if the name does not match
+func claimOrdinal(claimName, template, statefulSet string) (int, bool) {
+	prefix := template + "-" + statefulSet + "-"
+	if !strings.HasPrefix(claimName, prefix) {
+		return 0, false
+	}
+	suffix := strings.TrimPrefix(claimName, prefix)
+	ordinal, err := strconv.Atoi(suffix)
+	if err != nil || ordinal < 0 || strconv.Itoa(ordinal) != suffix {
+		return 0, false
+	}
+	return ordinal, true
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClaimOrdinal(t *testing.T) {
+	tests := []struct {
+		name        string
+		claimName   string
+		template    string
+		statefulSet string
+		wantOrdinal int
+		wantOK      bool
+	}{
+		{"first ordinal", "data-web-0", "data", "web", 0, true},
+		{"multi-digit ordinal", "data-web-12", "data", "web", 12, true},
+		{"set name with dashes", "data-my-web-3", "data", "my-web", 3, true},
+		{"set name ending in digits", "data-web-1-2", "data", "web-1", 2, true},
+		{"template with dashes and digits", "data-v2-web-1", "data-v2", "web", 1, true},
+		{"claim of a set with a longer name", "data-web-1-2", "data", "web", 0, false},
+		{"claim of a set with a shorter name", "data-web-1", "data", "web-1", 0, false},
+		{"other template", "logs-web-0", "data", "web", 0, false},
+		{"missing ordinal", "data-web-", "data", "web", 0, false},
+		{"no separator", "data-web", "data", "web", 0, false},
+		{"leading zero", "data-web-01", "data", "web", 0, false},
+		{"negative ordinal", "data-web--1", "data", "web", 0, false},
+		{"signed ordinal", "data-web-+1", "data", "web", 0, false},
+		{"non-numeric ordinal", "data-web-a", "data", "web", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ordinal, ok := claimOrdinal(tt.claimName, tt.template, tt.statefulSet)
+			if ordinal != tt.wantOrdinal || ok != tt.wantOK {
+				t.Errorf("claimOrdinal(%q, %q, %q) = %d, %v, want %d, %v",
+					tt.claimName, tt.template, tt.statefulSet, ordinal, ok, tt.wantOrdinal, tt.wantOK)
+			}
+		})
+	}
+}
+
+func testStatefulSet(name string, replicas int32, retention *appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy, templates ...string) *appsv1.StatefulSet {
+	sts := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:                             &replicas,
+			PersistentVolumeClaimRetentionPolicy: retention,
+		},
+	}
+	for _, template := range templates {
+		sts.Spec.VolumeClaimTemplates = append(sts.Spec.VolumeClaimTemplates, corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: template},
+		})
+	}
+	return sts
+}
+
+func testClaim(name string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+}
+
+// claimOrdinals returns the ordinal property of the claims relationships of a StatefulSet by claim name
+func claimOrdinals(t *testing.T, g *graph.Graph, sts graph.EntityKey) map[string]string {
+	t.Helper()
+	got := make(map[string]string)
+	for _, rel := range g.Outgoing(sts) {
+		if rel.RelationshipType != "claims" {
+			t.Errorf("unexpected %s relationship to %s", rel.RelationshipType, rel.Target)
+			continue
+		}
+		got[rel.Target.Name] = rel.Properties["ordinal"]
+	}
+	return got
+}
+
+func TestStatefulSetClaims(t *testing.T) {
+	web := graph.EntityKey{Name: "web", Namespace: "default", Type: "StatefulSet"}
+	web1 := graph.EntityKey{Name: "web-1", Namespace: "default", Type: "StatefulSet"}
+	retain := &appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+		WhenDeleted: appsv1.RetainPersistentVolumeClaimRetentionPolicyType,
+		WhenScaled:  appsv1.RetainPersistentVolumeClaimRetentionPolicyType,
+	}
+	deleteOnScaleDown := &appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+		WhenDeleted: appsv1.RetainPersistentVolumeClaimRetentionPolicyType,
+		WhenScaled:  appsv1.DeletePersistentVolumeClaimRetentionPolicyType,
+	}
+
+	informers := newTestInformers(t)
+	g := graph.NewGraph()
+	c := newStatefulSetClaims(g, informers)
+
+	// web-1 is a second set whose claims share web's prefix
+	addToCache(t, informers, "StatefulSet", testStatefulSet("web", 3, retain, "data"), testStatefulSet("web-1", 1, nil, "data"))
+	addToCache(t, informers, "PersistentVolumeClaim",
+		testClaim("data-web-0"), testClaim("data-web-1"), testClaim("data-web-2"),
+		testClaim("data-web-1-0"), testClaim("logs-web-0"))
+	c.resync()
+
+	want := map[string]string{"data-web-0": "0", "data-web-1": "1", "data-web-2": "2"}
+	if got := claimOrdinals(t, g, web); !reflect.DeepEqual(got, want) {
+		t.Fatalf("initial web claims = %v, want %v", got, want)
+	}
+	if got, want := claimOrdinals(t, g, web1), map[string]string{"data-web-1-0": "0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("initial web-1 claims = %v, want %v", got, want)
+	}
+
+	// Scaling down under the Retain policy keeps the claims and their relationships
+	addToCache(t, informers, "StatefulSet", testStatefulSet("web", 1, retain, "data"))
+	c.syncStatefulSet("default", "web")
+	if got := claimOrdinals(t, g, web); !reflect.DeepEqual(got, want) {
+		t.Fatalf("scaled down with retained claims = %v, want %v", got, want)
+	}
+
+	// Under the Delete policy the controller deletes the claims above the replica count
+	addToCache(t, informers, "StatefulSet", testStatefulSet("web", 1, deleteOnScaleDown, "data"))
+	c.syncStatefulSet("default", "web")
+	for _, name := range []string{"data-web-1", "data-web-2"} {
+		claim := testClaim(name)
+		deleteFromCache(t, informers, "PersistentVolumeClaim", claim)
+		c.syncClaim(claim)
+	}
+	want = map[string]string{"data-web-0": "0"}
+	if got := claimOrdinals(t, g, web); !reflect.DeepEqual(got, want) {
+		t.Fatalf("scaled down with deleted claims = %v, want %v", got, want)
+	}
+	if got, want := claimOrdinals(t, g, web1), map[string]string{"data-web-1-0": "0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("web-1 claims after web scaled down = %v, want %v", got, want)
+	}
+
+	// Scaling back up creates the claims again
+	addToCache(t, informers, "StatefulSet", testStatefulSet("web", 2, deleteOnScaleDown, "data"))
+	c.syncStatefulSet("default", "web")
+	claim := testClaim("data-web-1")
+	addToCache(t, informers, "PersistentVolumeClaim", claim)
+	c.syncClaim(claim)
+	want = map[string]string{"data-web-0": "0", "data-web-1": "1"}
+	if got := claimOrdinals(t, g, web); !reflect.DeepEqual(got, want) {
+		t.Fatalf("scaled up claims = %v, want %v", got, want)
+	}
+
+	// Deleting the set releases its relationships even though the claims are retained
+	sts := testStatefulSet("web", 2, deleteOnScaleDown, "data")
+	deleteFromCache(t, informers, "StatefulSet", sts)
+	c.syncStatefulSet("default", "web")
+	if got := g.Outgoing(web); len(got) != 0 {
+		t.Fatalf("deleted StatefulSet: got relationships %v, want none", got)
+	}
+}
@@ -0,0 +1,154 @@
+package extractor
+
+import (
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func init() {
+	register(typedExtractor[appsv1.ReplicaSet]{kind: "ReplicaSet", extract: extractReplicaSet})
+	register(typedExtractor[appsv1.Deployment]{kind: "Deployment", extract: extractDeployment})
+	register(typedExtractor[appsv1.StatefulSet]{kind: "StatefulSet", extract: extractStatefulSet})
+	register(typedExtractor[appsv1.DaemonSet]{kind: "DaemonSet", extract: extractDaemonSet})
+}
+
+func extractReplicaSet(o *appsv1.ReplicaSet) *Result {
+	key := graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "ReplicaSet"}
+	result := &Result{
+		Node: graph.GraphNode{
+			Key: key,
+			Properties: map[string]interface{}{
+				"replicas": replicasValue(o.Spec.Replicas),
+			},
+			Revision: 1,
+		},
+	}
+
+	// ReplicaSet -> ConfigMap/Secret relationships
+	result.Relationships = append(result.Relationships, podSpecRelationships(key, &o.Spec.Template.Spec)...)
+
+	// ReplicaSet -> Image relationships
+	related, rels := imageRelationships(key, &o.Spec.Template.Spec, nil)
+	result.Related = related
+	result.Relationships = append(result.Relationships, rels...)
+
+	return result
+}
+
+func extractDeployment(o *appsv1.Deployment) *Result {
+	key := graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "Deployment"}
+	result := &Result{
+		Node: graph.GraphNode{
+			Key: key,
+			Properties: map[string]interface{}{
+				"replicas": replicasValue(o.Spec.Replicas),
+			},
+			Revision: 1,
+		},
+	}
+
+	// Deployment -> ConfigMap/Secret relationships
+	result.Relationships = append(result.Relationships, podSpecRelationships(key, &o.Spec.Template.Spec)...)
+
+	// Deployment -> Image relationships
+	related, rels := imageRelationships(key, &o.Spec.Template.Spec, nil)
+	result.Related = related
+	result.Relationships = append(result.Relationships, rels...)
+
+	return result
+}
+
+func extractStatefulSet(o *appsv1.StatefulSet) *Result {
+	key := graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "StatefulSet"}
+	replicas := int32(1)
+	if o.Spec.Replicas != nil {
+		replicas = *o.Spec.Replicas
+	}
+
+	rolloutStatus := "progressing"
+	if o.Status.ObservedGeneration >= o.Generation &&
+		o.Status.UpdatedReplicas == replicas &&
+		o.Status.ReadyReplicas == replicas &&
+		o.Status.CurrentRevision == o.Status.UpdateRevision {
+		rolloutStatus = "complete"
+	}
+
+	result := &Result{
+		Node: graph.GraphNode{
+			Key: key,
+			Properties: map[string]interface{}{
+				"replicas":        replicas,
+				"readyReplicas":   o.Status.ReadyReplicas,
+				"currentReplicas": o.Status.CurrentReplicas,
+				"updatedReplicas": o.Status.UpdatedReplicas,
+				"currentRevision": o.Status.CurrentRevision,
+				"updateRevision":  o.Status.UpdateRevision,
+				"rolloutStatus":   rolloutStatus,
+			},
+			Revision: 1,
+		},
+	}
+
+	// StatefulSet -> governing headless Service relationship
+	if o.Spec.ServiceName != "" {
+		result.Relationships = append(result.Relationships, graph.GraphRelationship{
+			Source:           key,
+			Target:           graph.EntityKey{Name: o.Spec.ServiceName, Namespace: o.Namespace, Type: "Service"},
+			RelationshipType: "governed_by",
+		})
+	}
+
+	// StatefulSet -> ConfigMap/Secret relationships
+	result.Relationships = append(result.Relationships, podSpecRelationships(key, &o.Spec.Template.Spec)...)
+
+	// StatefulSet -> Image relationships
+	related, rels := imageRelationships(key, &o.Spec.Template.Spec, nil)
+	result.Related = related
+	result.Relationships = append(result.Relationships, rels...)
+
+	return result
+}
+
+func extractDaemonSet(o *appsv1.DaemonSet) *Result {
+	key := graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "DaemonSet"}
+
+	rolloutStatus := "progressing"
+	if o.Status.ObservedGeneration >= o.Generation &&
+		o.Status.UpdatedNumberScheduled == o.Status.DesiredNumberScheduled &&
+		o.Status.NumberAvailable == o.Status.DesiredNumberScheduled {
+		rolloutStatus = "complete"
+	}
+
+	result := &Result{
+		Node: graph.GraphNode{
+			Key: key,
+			Properties: map[string]interface{}{
+				"desiredNumberScheduled": o.Status.DesiredNumberScheduled,
+				"currentNumberScheduled": o.Status.CurrentNumberScheduled,
+				"updatedNumberScheduled": o.Status.UpdatedNumberScheduled,
+				"numberReady":            o.Status.NumberReady,
+				"numberAvailable":        o.Status.NumberAvailable,
+				"rolloutStatus":          rolloutStatus,
+			},
+			Revision: 1,
+		},
+	}
+
+	// DaemonSet -> ConfigMap/Secret relationships
+	result.Relationships = append(result.Relationships, podSpecRelationships(key, &o.Spec.Template.Spec)...)
+
+	// DaemonSet -> Image relationships
+	related, rels := imageRelationships(key, &o.Spec.Template.Spec, nil)
+	result.Related = related
+	result.Relationships = append(result.Relationships, rels...)
+
+	return result
+}
+
+// replicasValue returns an optional replica count, treating nil as the API default of 1
+func replicasValue(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
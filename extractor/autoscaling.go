@@ -0,0 +1,95 @@
+package extractor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+)
+
+func init() {
+	register(typedExtractor[autoscalingv2.HorizontalPodAutoscaler]{kind: "HorizontalPodAutoscaler", extract: extractHorizontalPodAutoscaler})
+}
+
+func extractHorizontalPodAutoscaler(o *autoscalingv2.HorizontalPodAutoscaler) *Result {
+	key := graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "HorizontalPodAutoscaler"}
+
+	metrics := make([]string, 0, len(o.Spec.Metrics))
+	for _, metric := range o.Spec.Metrics {
+		if formatted := metricString(metric); formatted != "" {
+			metrics = append(metrics, formatted)
+		}
+	}
+
+	result := &Result{
+		Node: graph.GraphNode{
+			Key: key,
+			Properties: map[string]interface{}{
+				"minReplicas":     replicasValue(o.Spec.MinReplicas),
+				"maxReplicas":     o.Spec.MaxReplicas,
+				"currentReplicas": o.Status.CurrentReplicas,
+				"desiredReplicas": o.Status.DesiredReplicas,
+				"metrics":         strings.Join(metrics, ","),
+			},
+			Revision: 1,
+		},
+	}
+
+	// HorizontalPodAutoscaler -> Deployment/StatefulSet/custom resource relationship
+	ref := o.Spec.ScaleTargetRef
+	if ref.Kind != "" && ref.Name != "" {
+		result.Relationships = append(result.Relationships, graph.GraphRelationship{
+			Source:           key,
+			Target:           graph.EntityKey{Name: ref.Name, Namespace: o.Namespace, Type: typeNameOf(ref.APIVersion, ref.Kind)},
+			RelationshipType: "scales",
+			Properties: map[string]string{
+				"apiVersion": ref.APIVersion,
+			},
+		})
+	}
+
+	return result
+}
+
+// metricString formats an HPA metric as source:metric:targetType=value,
+// e.g. resource:cpu:Utilization=80
+func metricString(metric autoscalingv2.MetricSpec) string {
+	switch {
+	case metric.Resource != nil:
+		return "resource:" + string(metric.Resource.Name) + ":" + metricTargetString(metric.Resource.Target)
+	case metric.ContainerResource != nil:
+		return "containerResource:" + metric.ContainerResource.Container + "/" + string(metric.ContainerResource.Name) + ":" +
+			metricTargetString(metric.ContainerResource.Target)
+	case metric.Pods != nil:
+		return "pods:" + metric.Pods.Metric.Name + ":" + metricTargetString(metric.Pods.Target)
+	case metric.Object != nil:
+		described := metric.Object.DescribedObject
+		return "object:" + described.Kind + "/" + described.Name + "/" + metric.Object.Metric.Name + ":" +
+			metricTargetString(metric.Object.Target)
+	case metric.External != nil:
+		return "external:" + metric.External.Metric.Name + ":" + metricTargetString(metric.External.Target)
+	default:
+		return ""
+	}
+}
+
+// metricTargetString formats a metric target as targetType=value
+func metricTargetString(target autoscalingv2.MetricTarget) string {
+	var value string
+	switch target.Type {
+	case autoscalingv2.UtilizationMetricType:
+		if target.AverageUtilization != nil {
+			value = fmt.Sprintf("%d", *target.AverageUtilization)
+		}
+	case autoscalingv2.AverageValueMetricType:
+		if target.AverageValue != nil {
+			value = target.AverageValue.String()
+		}
+	case autoscalingv2.ValueMetricType:
+		if target.Value != nil {
+			value = target.Value.String()
+		}
+	}
+	return string(target.Type) + "=" + value
+}
@@ -0,0 +1,102 @@
+package extractor
+
+import (
+	"time"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	register(typedExtractor[batchv1.Job]{kind: "Job", extract: extractJob})
+	register(typedExtractor[batchv1.CronJob]{kind: "CronJob", extract: extractCronJob})
+}
+
+func extractJob(o *batchv1.Job) *Result {
+	key := graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "Job"}
+
+	status := "running"
+	for _, condition := range o.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			status = "complete"
+		case batchv1.JobFailed:
+			status = "failed"
+		case batchv1.JobSuspended:
+			status = "suspended"
+		}
+	}
+
+	result := &Result{
+		Node: graph.GraphNode{
+			Key: key,
+			Properties: map[string]interface{}{
+				"status":         status,
+				"completions":    replicasValue(o.Spec.Completions),
+				"parallelism":    replicasValue(o.Spec.Parallelism),
+				"active":         o.Status.Active,
+				"succeeded":      o.Status.Succeeded,
+				"failed":         o.Status.Failed,
+				"startTime":      timeString(o.Status.StartTime),
+				"completionTime": timeString(o.Status.CompletionTime),
+			},
+			Revision: 1,
+		},
+	}
+
+	// Job -> ConfigMap/Secret relationships
+	result.Relationships = append(result.Relationships, podSpecRelationships(key, &o.Spec.Template.Spec)...)
+
+	// Job -> Image relationships
+	related, rels := imageRelationships(key, &o.Spec.Template.Spec, nil)
+	result.Related = related
+	result.Relationships = append(result.Relationships, rels...)
+
+	return result
+}
+
+func extractCronJob(o *batchv1.CronJob) *Result {
+	key := graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "CronJob"}
+
+	suspend := false
+	if o.Spec.Suspend != nil {
+		suspend = *o.Spec.Suspend
+	}
+
+	result := &Result{
+		Node: graph.GraphNode{
+			Key: key,
+			Properties: map[string]interface{}{
+				"schedule":           o.Spec.Schedule,
+				"suspend":            suspend,
+				"active":             len(o.Status.Active),
+				"lastScheduleTime":   timeString(o.Status.LastScheduleTime),
+				"lastSuccessfulTime": timeString(o.Status.LastSuccessfulTime),
+			},
+			Revision: 1,
+		},
+	}
+
+	// CronJob -> ConfigMap/Secret relationships
+	result.Relationships = append(result.Relationships, podSpecRelationships(key, &o.Spec.JobTemplate.Spec.Template.Spec)...)
+
+	// CronJob -> Image relationships
+	related, rels := imageRelationships(key, &o.Spec.JobTemplate.Spec.Template.Spec, nil)
+	result.Related = related
+	result.Relationships = append(result.Relationships, rels...)
+
+	return result
+}
+
+// timeString formats an optional timestamp as RFC 3339, or the empty string if unset
+func timeString(t *metav1.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,257 @@
+package extractor
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func init() {
+	register(typedExtractor[corev1.Pod]{kind: "Pod", extract: extractPod})
+	register(typedExtractor[corev1.Node]{kind: "Node", extract: extractNode})
+	register(typedExtractor[corev1.Namespace]{kind: "Namespace", extract: extractNamespace})
+	register(typedExtractor[corev1.Service]{kind: "Service", extract: extractService})
+	register(typedExtractor[corev1.ConfigMap]{kind: "ConfigMap", extract: extractConfigMap})
+	register(typedExtractor[corev1.Secret]{kind: "Secret", extract: extractSecret})
+	register(typedExtractor[corev1.ServiceAccount]{kind: "ServiceAccount", extract: extractServiceAccount})
+}
+
+func extractPod(o *corev1.Pod) *Result {
+	key := graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "Pod"}
+	result := &Result{
+		Node: graph.GraphNode{
+			Key: key,
+			Properties: map[string]interface{}{
+				"status": string(o.Status.Phase),
+			},
+			Revision: 1,
+		},
+	}
+
+	// Pod -> Node relationship
+	if o.Spec.NodeName != "" {
+		result.Relationships = append(result.Relationships, graph.GraphRelationship{
+			Source:           key,
+			Target:           graph.EntityKey{Name: o.Spec.NodeName, Type: "Node"},
+			RelationshipType: "runs_on",
+		})
+	}
+
+	// Pod -> ServiceAccount relationship
+	serviceAccount := o.Spec.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = o.Spec.DeprecatedServiceAccount
+	}
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	result.Relationships = append(result.Relationships, graph.GraphRelationship{
+		Source:           key,
+		Target:           graph.EntityKey{Name: serviceAccount, Namespace: o.Namespace, Type: "ServiceAccount"},
+		RelationshipType: "runs_as",
+	})
+
+	// Pod -> ConfigMap/Secret relationships
+	result.Relationships = append(result.Relationships, podSpecRelationships(key, &o.Spec)...)
+
+	// Pod -> PersistentVolumeClaim relationships
+	result.Relationships = append(result.Relationships, volumeRelationships(key, &o.Spec)...)
+
+	// Pod -> Image relationships, pinned to the digests the containers run
+	related, rels := imageRelationships(key, &o.Spec, containerDigests(&o.Status))
+	result.Related = related
+	result.Relationships = append(result.Relationships, rels...)
+
+	return result
+}
+
+// extractNode records a node's readiness, conditions, taints, allocatable
+// resources and zone. Node.Status.Phase is deprecated and never set, so the
+// status comes from the Ready condition instead.
+func extractNode(o *corev1.Node) *Result {
+	properties := map[string]interface{}{
+		"status":        "Unknown",
+		"unschedulable": o.Spec.Unschedulable,
+		"zone":          labelValue(o.Labels, corev1.LabelTopologyZone, corev1.LabelFailureDomainBetaZone),
+		"region":        labelValue(o.Labels, corev1.LabelTopologyRegion, corev1.LabelFailureDomainBetaRegion),
+	}
+
+	for _, condition := range o.Status.Conditions {
+		properties["condition."+string(condition.Type)] = string(condition.Status)
+		if condition.Type == corev1.NodeReady {
+			switch condition.Status {
+			case corev1.ConditionTrue:
+				properties["status"] = "Ready"
+			case corev1.ConditionFalse:
+				properties["status"] = "NotReady"
+			}
+		}
+	}
+
+	taints := make(map[string]struct{}, len(o.Spec.Taints))
+	for _, taint := range o.Spec.Taints {
+		taints[taint.ToString()] = struct{}{}
+	}
+	properties["taints"] = JoinSet(taints)
+
+	for resource, quantity := range o.Status.Allocatable {
+		properties["allocatable."+string(resource)] = quantity.String()
+	}
+
+	return &Result{
+		Node: graph.GraphNode{
+			Key:        graph.EntityKey{Name: o.Name, Namespace: "", Type: "Node"},
+			Properties: properties,
+			Revision:   1,
+		},
+	}
+}
+
+// labelValue returns the value of the first of keys present in labels, or
+// an empty string if none are
+func labelValue(labels map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := labels[key]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// extractNamespace records a Namespace's phase, labels and annotations.
+// Labels and annotations become properties prefixed with "label." and
+// "annotation." so namespaces can be sliced by ownership labels.
+func extractNamespace(o *corev1.Namespace) *Result {
+	properties := map[string]interface{}{
+		"phase": string(o.Status.Phase),
+	}
+	for k, v := range o.Labels {
+		properties["label."+k] = v
+	}
+	for k, v := range o.Annotations {
+		// The last applied configuration duplicates the whole object
+		if k == corev1.LastAppliedConfigAnnotation {
+			continue
+		}
+		properties["annotation."+k] = v
+	}
+
+	return &Result{
+		Node: graph.GraphNode{
+			Key:        graph.EntityKey{Name: o.Name, Namespace: "", Type: "Namespace"},
+			Properties: properties,
+			Revision:   1,
+		},
+	}
+}
+
+func extractService(o *corev1.Service) *Result {
+	return &Result{
+		Node: graph.GraphNode{
+			Key: graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "Service"},
+			Properties: map[string]interface{}{
+				"type": string(o.Spec.Type),
+			},
+			Revision: 1,
+		},
+	}
+}
+
+// extractConfigMap records a ConfigMap's key names and a keyed hash of its
+// contents. ConfigMaps regularly hold credentials despite their name, so
+// their values are treated like those of Secrets.
+func extractConfigMap(o *corev1.ConfigMap) *Result {
+	data := make(map[string][]byte, len(o.Data)+len(o.BinaryData))
+	for k, v := range o.Data {
+		data[k] = []byte(v)
+	}
+	for k, v := range o.BinaryData {
+		data[k] = v
+	}
+	keys, hash := contentHash(data)
+
+	return &Result{
+		Node: graph.GraphNode{
+			Key: graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "ConfigMap"},
+			Properties: map[string]interface{}{
+				"keys":        strings.Join(keys, ","),
+				"contentHash": hash,
+			},
+			Revision: 1,
+		},
+	}
+}
+
+// secretHashKey keys the HMAC of Secret and ConfigMap contents. Without a
+// key, a plain hash of a low-entropy value could be brute-forced offline.
+var secretHashKey []byte
+
+// SetSecretHashKey sets the key for the contentHash of Secret and ConfigMap
+// nodes. Hashes are only comparable between runs that use the same key.
+func SetSecretHashKey(key []byte) {
+	secretHashKey = key
+}
+
+// contentHash returns the sorted keys of data and a keyed hash of its
+// contents, which changes whenever a key or value does
+func contentHash(data map[string][]byte) ([]string, string) {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	hash := hmac.New(sha256.New, secretHashKey)
+	for _, k := range keys {
+		hash.Write([]byte(k))
+		hash.Write([]byte{0})
+		hash.Write(data[k])
+		hash.Write([]byte{0})
+	}
+	return keys, "hmac-sha256:" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// extractSecret records a Secret's type, key names and a keyed hash of its
+// contents. Secret values never leave this function.
+func extractSecret(o *corev1.Secret) *Result {
+	data := make(map[string][]byte, len(o.Data)+len(o.StringData))
+	for k, v := range o.Data {
+		data[k] = v
+	}
+	for k, v := range o.StringData {
+		data[k] = []byte(v)
+	}
+	keys, hash := contentHash(data)
+
+	return &Result{
+		Node: graph.GraphNode{
+			Key: graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "Secret"},
+			Properties: map[string]interface{}{
+				"type":        string(o.Type),
+				"keys":        strings.Join(keys, ","),
+				"contentHash": hash,
+			},
+			Revision: 1,
+		},
+	}
+}
+
+func extractServiceAccount(o *corev1.ServiceAccount) *Result {
+	// Tokens are mounted unless the ServiceAccount opts out
+	automount := o.AutomountServiceAccountToken == nil || *o.AutomountServiceAccountToken
+
+	return &Result{
+		Node: graph.GraphNode{
+			Key: graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "ServiceAccount"},
+			Properties: map[string]interface{}{
+				"automountServiceAccountToken": automount,
+			},
+			Revision: 1,
+		},
+	}
+}
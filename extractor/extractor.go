@@ -0,0 +1,260 @@
+package extractor
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// Result holds the graph elements extracted from a single Kubernetes object.
+// Related holds nodes with no object of their own that the object's
+// relationships point at, such as the users and groups a binding names.
+type Result struct {
+	Node          graph.GraphNode
+	Related       []graph.GraphNode
+	Relationships []graph.GraphRelationship
+}
+
+// Extractor converts objects of a single resource kind into graph elements.
+// Objects may be passed either as typed API objects (as delivered by the
+// typed informers) or in unstructured form (as delivered by the dynamic
+// informers).
+type Extractor interface {
+	Kind() string
+	Extract(obj interface{}) (*Result, error)
+}
+
+// typedExtractor adapts a function over a typed API object to the Extractor interface
+type typedExtractor[T any] struct {
+	kind    string
+	extract func(obj *T) *Result
+}
+
+func (e typedExtractor[T]) Kind() string {
+	return e.kind
+}
+
+// group returns the API group of the kind
+func (e typedExtractor[T]) group() string {
+	gv, _ := schema.ParseGroupVersion(apiVersionOf(new(T)))
+	return gv.Group
+}
+
+func (e typedExtractor[T]) Extract(obj interface{}) (*Result, error) {
+	typed, err := Convert[T](obj)
+	if err != nil {
+		return nil, fmt.Errorf("error converting %s: %v", e.kind, err)
+	}
+
+	result := e.extract(typed)
+	if err := addSchemaProperties(result, e.kind, typed); err != nil {
+		return nil, err
+	}
+
+	var owners []metav1.OwnerReference
+	if object, err := meta.Accessor(typed); err == nil {
+		owners = object.GetOwnerReferences()
+		setIdentity(&result.Node, object, apiVersionOf(typed))
+	}
+	addCommonRelationships(result, owners)
+
+	return result, nil
+}
+
+// setIdentity records the UID, apiVersion and creation time of the object
+// a node stands for
+func setIdentity(node *graph.GraphNode, object metav1.Object, apiVersion string) {
+	node.UID = string(object.GetUID())
+	node.APIVersion = apiVersion
+	if created := object.GetCreationTimestamp(); !created.IsZero() {
+		t := created.Time
+		node.Created = &t
+	}
+}
+
+// apiVersionOf returns the apiVersion of a typed API object. Objects
+// delivered by informers have no TypeMeta, so it is looked up by Go type.
+func apiVersionOf(obj interface{}) string {
+	object, ok := obj.(runtime.Object)
+	if !ok {
+		return ""
+	}
+	gvks, _, err := scheme.Scheme.ObjectKinds(object)
+	if err != nil || len(gvks) == 0 {
+		return ""
+	}
+	return gvks[0].GroupVersion().String()
+}
+
+// addCommonRelationships appends the relationships every resource has to
+// those extracted for its kind
+func addCommonRelationships(result *Result, owners []metav1.OwnerReference) {
+	// Every resource -> owner relationships
+	result.Relationships = append(result.Relationships, ownerRelationships(result.Node.Key, owners)...)
+
+	// Every namespaced resource -> Namespace relationship
+	if key := result.Node.Key; key.Namespace != "" {
+		result.Relationships = append(result.Relationships, graph.GraphRelationship{
+			Source:           key,
+			Target:           graph.EntityKey{Name: key.Namespace, Type: "Namespace"},
+			RelationshipType: "in_namespace",
+		})
+	}
+}
+
+var registry = map[string]Extractor{}
+
+// builtinGroups holds the API group of each kind with a typed extractor
+var builtinGroups = map[string]string{}
+
+func register(e Extractor) {
+	registry[e.Kind()] = e
+	if typed, ok := e.(interface{ group() string }); ok {
+		builtinGroups[e.Kind()] = typed.group()
+	}
+}
+
+// TypeName returns the node type of objects of kind in an API group. Kinds
+// with a typed extractor, and kinds of the core group, are named by their
+// kind alone. Any other kind is qualified by its group, such as
+// Service.serving.knative.dev, so that kinds sharing a name in different
+// groups stay apart.
+func TypeName(group, kind string) string {
+	if group == "" || builtinGroups[kind] == group {
+		return kind
+	}
+	return kind + "." + group
+}
+
+// typeNameOf returns the node type of objects of kind in the group version
+// named by apiVersion, as referenced by ownerReferences and other object
+// references
+func typeNameOf(apiVersion, kind string) string {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return kind
+	}
+	return TypeName(gv.Group, kind)
+}
+
+// For returns the extractor registered for the given kind
+func For(kind string) (Extractor, bool) {
+	e, ok := registry[kind]
+	return e, ok
+}
+
+// Extract converts obj into graph elements using the extractor registered for kind
+func Extract(kind string, obj interface{}) (*Result, error) {
+	e, ok := For(kind)
+	if !ok {
+		return nil, fmt.Errorf("no extractor registered for kind %s", kind)
+	}
+	return e.Extract(obj)
+}
+
+// ScopeResolver reports whether objects of a kind, in the group version
+// named by apiVersion, are namespaced
+type ScopeResolver func(apiVersion, kind string) (bool, error)
+
+// resolveScope resolves the scope of owners, and unresolvedOwners holds the
+// owner kinds whose scope could not be resolved, which are only logged once
+var (
+	resolveScope     ScopeResolver
+	unresolvedOwners sync.Map
+)
+
+// SetScopeResolver sets how the scope of an owner is resolved from its
+// ownerReference, typically through API discovery. Without a resolver every
+// owner of a namespaced object is taken to be namespaced.
+func SetScopeResolver(resolver ScopeResolver) {
+	resolveScope = resolver
+}
+
+// ownerNamespace returns the namespace of an owner of an object in
+// namespace. An ownerReference cannot cross namespaces, so a namespaced
+// owner shares the object's namespace, while a cluster-scoped owner has
+// none. Owners whose scope cannot be resolved are taken to be namespaced.
+func ownerNamespace(namespace string, owner metav1.OwnerReference) string {
+	if namespace == "" || resolveScope == nil {
+		return namespace
+	}
+
+	namespaced, err := resolveScope(owner.APIVersion, owner.Kind)
+	if err != nil {
+		if _, logged := unresolvedOwners.LoadOrStore(owner.APIVersion+"/"+owner.Kind, struct{}{}); !logged {
+			log.Printf("Error resolving the scope of owner kind %s in %s, taking it to be namespaced: %v", owner.Kind, owner.APIVersion, err)
+		}
+		return namespace
+	}
+	if !namespaced {
+		return ""
+	}
+	return namespace
+}
+
+// ownerRelationships returns owned_by relationships from key to each of its
+// owners, whatever their kind. The relationships record whether the owner is
+// the managing controller, whether it blocks foreground deletion and its UID.
+func ownerRelationships(key graph.EntityKey, owners []metav1.OwnerReference) []graph.GraphRelationship {
+	rels := make([]graph.GraphRelationship, 0, len(owners))
+	for _, owner := range owners {
+		namespace := ownerNamespace(key.Namespace, owner)
+		rels = append(rels, graph.GraphRelationship{
+			Source:           key,
+			Target:           graph.EntityKey{Name: owner.Name, Namespace: namespace, Type: typeNameOf(owner.APIVersion, owner.Kind)},
+			RelationshipType: "owned_by",
+			Properties: map[string]string{
+				"controller":         fmt.Sprintf("%t", owner.Controller != nil && *owner.Controller),
+				"blockOwnerDeletion": fmt.Sprintf("%t", owner.BlockOwnerDeletion != nil && *owner.BlockOwnerDeletion),
+				"uid":                string(owner.UID),
+			},
+		})
+	}
+	return rels
+}
+
+// JoinSet returns the members of a set sorted and comma-separated, the form
+// multi-valued properties take in the graph
+func JoinSet(set map[string]struct{}) string {
+	members := make([]string, 0, len(set))
+	for member := range set {
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	return strings.Join(members, ",")
+}
+
+// Convert returns obj as a typed API object, converting it from its
+// unstructured form if necessary.
+func Convert[T any](obj interface{}) (*T, error) {
+	switch o := obj.(type) {
+	case *T:
+		return o, nil
+	case T:
+		return &o, nil
+	case map[string]interface{}:
+		return fromUnstructured[T](o)
+	case *unstructured.Unstructured:
+		return fromUnstructured[T](o.Object)
+	default:
+		return nil, fmt.Errorf("unsupported object type %T", obj)
+	}
+}
+
+func fromUnstructured[T any](u map[string]interface{}) (*T, error) {
+	typed := new(T)
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u, typed); err != nil {
+		return nil, err
+	}
+	return typed, nil
+}
@@ -0,0 +1,1203 @@
+package extractor
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// secretValues are the values of the objects in TestValuesNotExtracted,
+// none of which may appear in the graph
+var secretValues = []string{"hunter2", "s3cr3t-token", "binary-payload", "postgres://admin:pw@db"}
+
+// withHashKey sets the content hash key for the duration of a test
+func withHashKey(t *testing.T, key string) {
+	previous := secretHashKey
+	SetSecretHashKey([]byte(key))
+	t.Cleanup(func() { SetSecretHashKey(previous) })
+}
+
+// assertNoValues fails if any of secretValues, or their base64 encoding,
+// appears anywhere in result
+func assertNoValues(t *testing.T, result *Result) {
+	t.Helper()
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("marshaling result: %v", err)
+	}
+	for _, value := range secretValues {
+		for _, form := range []string{value, base64.StdEncoding.EncodeToString([]byte(value))} {
+			if strings.Contains(string(data), form) {
+				t.Errorf("extracted %s contains the value %q: %s", result.Node.Key, form, data)
+			}
+		}
+	}
+}
+
+func TestValuesNotExtracted(t *testing.T) {
+	withHashKey(t, "key-1")
+	objectMeta := metav1.ObjectMeta{Name: "credentials", Namespace: "default", UID: "uid-1"}
+
+	tests := []struct {
+		name     string
+		kind     string
+		obj      interface{}
+		wantKeys string
+	}{
+		{"secret", "Secret", &corev1.Secret{
+			ObjectMeta: objectMeta,
+			Type:       corev1.SecretTypeOpaque,
+			Data:       map[string][]byte{"password": []byte("hunter2"), "token": []byte("s3cr3t-token")},
+			StringData: map[string]string{"url": "postgres://admin:pw@db"},
+		}, "password,token,url"},
+		{"configmap", "ConfigMap", &corev1.ConfigMap{
+			ObjectMeta: objectMeta,
+			Data:       map[string]string{"password": "hunter2"},
+			BinaryData: map[string][]byte{"blob": []byte("binary-payload")},
+		}, "blob,password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Extract(tt.kind, tt.obj)
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			assertNoValues(t, result)
+			if got := result.Node.Properties["keys"]; got != tt.wantKeys {
+				t.Errorf("keys = %v, want %s", got, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	secret := func(password string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"},
+			Data:       map[string][]byte{"password": []byte(password)},
+		}
+	}
+	hash := func(obj *corev1.Secret) interface{} {
+		result, err := Extract("Secret", obj)
+		if err != nil {
+			t.Fatalf("Extract() error = %v", err)
+		}
+		return result.Node.Properties["contentHash"]
+	}
+
+	withHashKey(t, "key-1")
+	first := hash(secret("hunter2"))
+	if again := hash(secret("hunter2")); again != first {
+		t.Errorf("contentHash of the same contents = %v, then %v", first, again)
+	}
+	if changed := hash(secret("hunter3")); changed == first {
+		t.Errorf("contentHash did not change with the value")
+	}
+
+	withHashKey(t, "key-2")
+	if rekeyed := hash(secret("hunter2")); rekeyed == first {
+		t.Errorf("contentHash did not change with the hash key")
+	}
+}
+
+func TestIngressRoutes(t *testing.T) {
+	implementationSpecific := networkingv1.PathTypeImplementationSpecific
+	backend := func(name string) *networkingv1.IngressServiceBackend {
+		return &networkingv1.IngressServiceBackend{Name: name, Port: networkingv1.ServiceBackendPort{Number: 80}}
+	}
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: networkingv1.IngressSpec{
+			DefaultBackend: &networkingv1.IngressBackend{Service: backend("web")},
+			Rules: []networkingv1.IngressRule{{
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{
+					Paths: []networkingv1.HTTPIngressPath{
+						{PathType: &implementationSpecific, Backend: networkingv1.IngressBackend{Service: backend("web")}},
+					},
+				}},
+			}},
+		},
+	}
+
+	result, err := Extract("Ingress", ingress)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	qualifiers := map[string]bool{}
+	for _, rel := range result.Relationships {
+		switch rel.RelationshipType {
+		case "routes_to":
+			qualifiers[rel.Qualifier] = true
+		case "uses_class":
+			t.Errorf("Ingress naming no class uses_class %v", rel.Target)
+		}
+	}
+	if len(qualifiers) != 2 {
+		t.Errorf("routes_to qualifiers = %v, want distinct ones for the default backend and the hostless rule", qualifiers)
+	}
+}
+
+func TestOwnerNamespace(t *testing.T) {
+	previous := resolveScope
+	t.Cleanup(func() { SetScopeResolver(previous) })
+	SetScopeResolver(func(apiVersion, kind string) (bool, error) {
+		switch kind {
+		case "ClusterIssuer", "PriorityClass":
+			return false, nil
+		case "ReplicaSet":
+			return true, nil
+		}
+		return false, fmt.Errorf("no matches for kind %s in %s", kind, apiVersion)
+	})
+
+	certificate := graph.EntityKey{Name: "web-tls", Namespace: "default", Type: "Certificate.cert-manager.io"}
+	owners := []metav1.OwnerReference{
+		{APIVersion: "cert-manager.io/v1", Kind: "ClusterIssuer", Name: "letsencrypt"},
+		{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-5d4f"},
+		{APIVersion: "example.com/v1", Kind: "Unknown", Name: "thing"},
+	}
+	want := map[string]string{"ClusterIssuer.cert-manager.io": "", "ReplicaSet": "default", "Unknown.example.com": "default"}
+
+	rels := ownerRelationships(certificate, owners)
+	if len(rels) != len(want) {
+		t.Fatalf("got %d owner relationships, want %d", len(rels), len(want))
+	}
+	for _, rel := range rels {
+		if _, ok := want[rel.Target.Type]; !ok {
+			t.Errorf("owner type %s, want one of %v", rel.Target.Type, want)
+		}
+		if got := rel.Target.Namespace; got != want[rel.Target.Type] {
+			t.Errorf("owner %s namespace = %q, want %q", rel.Target.Type, got, want[rel.Target.Type])
+		}
+	}
+
+	// Owners of cluster-scoped objects are cluster-scoped
+	for _, rel := range ownerRelationships(graph.EntityKey{Name: "node-1", Type: "Node"}, owners) {
+		if rel.Target.Namespace != "" {
+			t.Errorf("owner %s of a cluster-scoped object has namespace %q", rel.Target.Type, rel.Target.Namespace)
+		}
+	}
+}
+
+func TestParseImageReference(t *testing.T) {
+	tests := []struct {
+		image string
+		want  imageReference
+	}{
+		{"nginx", imageReference{registry: "docker.io", repository: "library/nginx", tag: "latest"}},
+		{"nginx:1.25", imageReference{registry: "docker.io", repository: "library/nginx", tag: "1.25"}},
+		{"bitnami/redis:7.2", imageReference{registry: "docker.io", repository: "bitnami/redis", tag: "7.2"}},
+		{"docker.io/nginx", imageReference{registry: "docker.io", repository: "library/nginx", tag: "latest"}},
+		{"index.docker.io/library/nginx:1.25", imageReference{registry: "docker.io", repository: "library/nginx", tag: "1.25"}},
+		{"registry.example.com:5000/team/app", imageReference{registry: "registry.example.com:5000", repository: "team/app", tag: "latest"}},
+		{"registry.example.com:5000/team/app:v2", imageReference{registry: "registry.example.com:5000", repository: "team/app", tag: "v2"}},
+		{"localhost/app", imageReference{registry: "localhost", repository: "app", tag: "latest"}},
+		{"ghcr.io/org/app@sha256:abc", imageReference{registry: "ghcr.io", repository: "org/app", digest: "sha256:abc"}},
+		{"ghcr.io/org/app:1.0@sha256:abc", imageReference{registry: "ghcr.io", repository: "org/app", tag: "1.0", digest: "sha256:abc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image, func(t *testing.T) {
+			if got := parseImageReference(tt.image); got != tt.want {
+				t.Errorf("parseImageReference(%q) = %+v, want %+v", tt.image, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContainerDigests(t *testing.T) {
+	status := &corev1.PodStatus{
+		InitContainerStatuses: []corev1.ContainerStatus{
+			{Name: "migrate", ImageID: "docker-pullable://ghcr.io/org/migrate@sha256:111"},
+		},
+		ContainerStatuses: []corev1.ContainerStatus{
+			{Name: "web", ImageID: "docker.io/library/nginx@sha256:222"},
+			{Name: "sidecar", ImageID: "sha256:333"},
+			{Name: "pending"},
+		},
+		EphemeralContainerStatuses: []corev1.ContainerStatus{
+			{Name: "debug", ImageID: "docker-pullable://busybox@sha256:444"},
+		},
+	}
+	want := map[string]string{"migrate": "sha256:111", "web": "sha256:222", "debug": "sha256:444"}
+
+	got := containerDigests(status)
+	if len(got) != len(want) {
+		t.Errorf("containerDigests() = %v, want %v", got, want)
+	}
+	for name, digest := range want {
+		if got[name] != digest {
+			t.Errorf("digest of %s = %q, want %q", name, got[name], digest)
+		}
+	}
+}
+
+func TestImageIDDigest(t *testing.T) {
+	tests := []struct {
+		imageID string
+		want    string
+	}{
+		{"docker-pullable://nginx@sha256:abc", "sha256:abc"},
+		{"docker.io/library/nginx@sha256:abc", "sha256:abc"},
+		{"sha256:abc", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := imageIDDigest(tt.imageID); got != tt.want {
+			t.Errorf("imageIDDigest(%q) = %q, want %q", tt.imageID, got, tt.want)
+		}
+	}
+}
+
+func TestImageRelationships(t *testing.T) {
+	pod := graph.EntityKey{Name: "web-0", Namespace: "default", Type: "Pod"}
+	image := func(name string) graph.EntityKey { return graph.EntityKey{Name: name, Type: "Image"} }
+	spec := &corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "migrate", Image: "ghcr.io/org/migrate:1.0"}},
+		Containers: []corev1.Container{
+			{Name: "web", Image: "nginx:1.25"},
+			{Name: "proxy", Image: "nginx:1.25"},
+			{Name: "pinned", Image: "nginx:1.25@sha256:222"},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		digests      map[string]string
+		wantImages   map[string]graph.EntityKey
+		wantResolved map[graph.EntityKey]graph.EntityKey
+		wantRelated  []graph.EntityKey
+	}{
+		{
+			name:    "template",
+			digests: nil,
+			wantImages: map[string]graph.EntityKey{
+				"migrate": image("ghcr.io/org/migrate:1.0"),
+				"web":     image("docker.io/library/nginx:1.25"),
+				"proxy":   image("docker.io/library/nginx:1.25"),
+				"pinned":  image("docker.io/library/nginx:1.25@sha256:222"),
+			},
+			wantResolved: map[graph.EntityKey]graph.EntityKey{},
+			wantRelated: []graph.EntityKey{
+				image("ghcr.io/org/migrate:1.0"),
+				image("docker.io/library/nginx:1.25"),
+				image("docker.io/library/nginx:1.25@sha256:222"),
+			},
+		},
+		{
+			name:    "pod with resolved digests",
+			digests: map[string]string{"web": "sha256:222", "proxy": "sha256:222", "pinned": "sha256:222"},
+			wantImages: map[string]graph.EntityKey{
+				"migrate": image("ghcr.io/org/migrate:1.0"),
+				"web":     image("docker.io/library/nginx:1.25@sha256:222"),
+				"proxy":   image("docker.io/library/nginx:1.25@sha256:222"),
+				"pinned":  image("docker.io/library/nginx:1.25@sha256:222"),
+			},
+			wantResolved: map[graph.EntityKey]graph.EntityKey{
+				image("docker.io/library/nginx:1.25@sha256:222"): image("docker.io/library/nginx:1.25"),
+			},
+			wantRelated: []graph.EntityKey{
+				image("ghcr.io/org/migrate:1.0"),
+				image("docker.io/library/nginx:1.25"),
+				image("docker.io/library/nginx:1.25@sha256:222"),
+			},
+		},
+		{
+			name:    "tag moved between pulls",
+			digests: map[string]string{"migrate": "sha256:111", "web": "sha256:222", "proxy": "sha256:333"},
+			wantImages: map[string]graph.EntityKey{
+				"migrate": image("ghcr.io/org/migrate:1.0@sha256:111"),
+				"web":     image("docker.io/library/nginx:1.25@sha256:222"),
+				"proxy":   image("docker.io/library/nginx:1.25@sha256:333"),
+				"pinned":  image("docker.io/library/nginx:1.25@sha256:222"),
+			},
+			wantResolved: map[graph.EntityKey]graph.EntityKey{
+				image("ghcr.io/org/migrate:1.0@sha256:111"):      image("ghcr.io/org/migrate:1.0"),
+				image("docker.io/library/nginx:1.25@sha256:222"): image("docker.io/library/nginx:1.25"),
+				image("docker.io/library/nginx:1.25@sha256:333"): image("docker.io/library/nginx:1.25"),
+			},
+			wantRelated: []graph.EntityKey{
+				image("ghcr.io/org/migrate:1.0"),
+				image("ghcr.io/org/migrate:1.0@sha256:111"),
+				image("docker.io/library/nginx:1.25"),
+				image("docker.io/library/nginx:1.25@sha256:222"),
+				image("docker.io/library/nginx:1.25@sha256:333"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			related, rels := imageRelationships(pod, spec, tt.digests)
+
+			var gotRelated []graph.EntityKey
+			for _, node := range related {
+				gotRelated = append(gotRelated, node.Key)
+			}
+			if !reflect.DeepEqual(gotRelated, tt.wantRelated) {
+				t.Errorf("related = %v, want %v", gotRelated, tt.wantRelated)
+			}
+
+			gotImages := make(map[string]graph.EntityKey)
+			gotResolved := make(map[graph.EntityKey]graph.EntityKey)
+			for _, rel := range rels {
+				switch rel.RelationshipType {
+				case "runs_image":
+					if rel.Source != pod {
+						t.Errorf("runs_image source = %v, want %v", rel.Source, pod)
+					}
+					gotImages[rel.Qualifier] = rel.Target
+				case "resolved_from":
+					if _, ok := gotResolved[rel.Source]; ok {
+						t.Errorf("duplicate resolved_from relationship from %v", rel.Source)
+					}
+					gotResolved[rel.Source] = rel.Target
+				default:
+					t.Errorf("unexpected %s relationship", rel.RelationshipType)
+				}
+			}
+			if !reflect.DeepEqual(gotImages, tt.wantImages) {
+				t.Errorf("runs_image = %v, want %v", gotImages, tt.wantImages)
+			}
+			if !reflect.DeepEqual(gotResolved, tt.wantResolved) {
+				t.Errorf("resolved_from = %v, want %v", gotResolved, tt.wantResolved)
+			}
+		})
+	}
+}
+
+func TestSchemaPropertiesExcludeValues(t *testing.T) {
+	withHashKey(t, "key-1")
+	t.Cleanup(func() { delete(schemas, "Secret") })
+	err := AddPropertySchema("Secret", map[string]PropertySpec{
+		"data":        {Path: "{.data}", Type: PropertyMap},
+		"password":    {Path: "{.data.password}"},
+		"url":         {Path: "{.stringData.url}"},
+		"everything":  {Path: "{..}", Type: PropertyList},
+		"annotations": {Path: "{.metadata.annotations}", Type: PropertyMap},
+		"owner":       {Path: "{.metadata.annotations.owner}"},
+	})
+	if err != nil {
+		t.Fatalf("AddPropertySchema() error = %v", err)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "credentials",
+			Namespace: "default",
+			Annotations: map[string]string{
+				"owner":               "payments",
+				lastAppliedAnnotation: `{"stringData":{"url":"postgres://admin:pw@db"}}`,
+			},
+		},
+		Data:       map[string][]byte{"password": []byte("hunter2")},
+		StringData: map[string]string{"url": "postgres://admin:pw@db"},
+	}
+	result, err := Extract("Secret", secret)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	assertNoValues(t, result)
+	if got := result.Node.Properties["owner"]; got != "payments" {
+		t.Errorf("owner = %v, want payments", got)
+	}
+
+	// The unstructured object, as a dynamic informer caches it, is left intact
+	u := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":        "credentials",
+			"namespace":   "default",
+			"annotations": map[string]interface{}{lastAppliedAnnotation: "{}"},
+		},
+		"data": map[string]interface{}{"password": base64.StdEncoding.EncodeToString([]byte("hunter2"))},
+	}
+	result = &Result{}
+	if err := addSchemaProperties(result, "Secret", u); err != nil {
+		t.Fatalf("addSchemaProperties() error = %v", err)
+	}
+	assertNoValues(t, result)
+	if _, ok := u["data"]; !ok {
+		t.Errorf("data removed from the object")
+	}
+	if annotations := u["metadata"].(map[string]interface{})["annotations"].(map[string]interface{}); len(annotations) != 1 {
+		t.Errorf("annotations of the object = %v", annotations)
+	}
+}
+
+func TestTypedProperties(t *testing.T) {
+	replicas := int32(3)
+	suspend := true
+	objectMeta := metav1.ObjectMeta{Name: "web", Namespace: "default"}
+
+	tests := []struct {
+		kind string
+		obj  interface{}
+		want string
+	}{
+		{"StatefulSet", &appsv1.StatefulSet{
+			ObjectMeta: objectMeta,
+			Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+			Status:     appsv1.StatefulSetStatus{ReadyReplicas: 2},
+		}, `"readyReplicas":2,"replicas":3`},
+		{"Deployment", &appsv1.Deployment{ObjectMeta: objectMeta}, `"replicas":1`},
+		{"CronJob", &batchv1.CronJob{
+			ObjectMeta: objectMeta,
+			Spec:       batchv1.CronJobSpec{Schedule: "@daily", Suspend: &suspend},
+		}, `"schedule":"@daily","suspend":true`},
+		{"Node", &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}, `"unschedulable":false`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			result, err := Extract(tt.kind, tt.obj)
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			data, err := json.Marshal(result.Node.Properties)
+			if err != nil {
+				t.Fatalf("marshaling properties: %v", err)
+			}
+			if !strings.Contains(string(data), tt.want) {
+				t.Errorf("properties = %s, want them to contain %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertProperty(t *testing.T) {
+	tests := []struct {
+		name   string
+		typ    PropertyType
+		values []interface{}
+		want   interface{}
+		wantOK bool
+	}{
+		{"no match", PropertyString, nil, nil, false},
+		{"string", PropertyString, []interface{}{"web"}, "web", true},
+		{"strings joined", PropertyString, []interface{}{"a", "b"}, "a,b", true},
+		{"non-string as JSON", PropertyString, []interface{}{int64(3), map[string]interface{}{"k": "v"}}, `3,{"k":"v"}`, true},
+		{"integer", PropertyNumber, []interface{}{int64(3)}, float64(3), true},
+		{"numbers summed", PropertyNumber, []interface{}{int64(1), int32(2), 0.5}, 3.5, true},
+		{"millicores", PropertyNumber, []interface{}{"500m", "250m"}, 0.75, true},
+		{"binary quantity", PropertyNumber, []interface{}{"1Gi"}, float64(1 << 30), true},
+		{"not a number", PropertyNumber, []interface{}{"many"}, nil, false},
+		{"bool", PropertyBool, []interface{}{true}, true, true},
+		{"bool strings", PropertyBool, []interface{}{"True", "true"}, true, true},
+		{"bool any false", PropertyBool, []interface{}{true, "False"}, false, true},
+		{"not a bool", PropertyBool, []interface{}{"Unknown"}, nil, false},
+		{"bool from number", PropertyBool, []interface{}{int64(1)}, nil, false},
+		{"single list", PropertyList, []interface{}{[]interface{}{"a", "b"}}, []interface{}{"a", "b"}, true},
+		{"matches as list", PropertyList, []interface{}{"a", "b"}, []interface{}{"a", "b"}, true},
+		{"single value as list", PropertyList, []interface{}{"a"}, []interface{}{"a"}, true},
+		{"map", PropertyMap, []interface{}{map[string]interface{}{"app": "web"}}, map[string]interface{}{"app": "web"}, true},
+		{"several maps", PropertyMap, []interface{}{map[string]interface{}{}, map[string]interface{}{}}, nil, false},
+		{"not a map", PropertyMap, []interface{}{"web"}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := convertProperty(tt.typ, tt.values)
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convertProperty(%s, %v) = %#v, %t, want %#v, %t", tt.typ, tt.values, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	tests := []struct {
+		group, kind string
+		want        string
+	}{
+		{"", "Pod", "Pod"},
+		{"apps", "Deployment", "Deployment"},
+		{"", "ResourceQuota", "ResourceQuota"},
+		{"serving.knative.dev", "Service", "Service.serving.knative.dev"},
+		{"argoproj.io", "Rollout", "Rollout.argoproj.io"},
+		{"extensions", "Deployment", "Deployment.extensions"},
+	}
+	for _, tt := range tests {
+		if got := TypeName(tt.group, tt.kind); got != tt.want {
+			t.Errorf("TypeName(%q, %q) = %q, want %q", tt.group, tt.kind, got, tt.want)
+		}
+	}
+}
+
+func TestUnstructuredExtractor(t *testing.T) {
+	const kind = "Service.serving.knative.dev"
+	t.Cleanup(func() {
+		delete(registry, kind)
+		delete(schemas, kind)
+	})
+	err := RegisterUnstructured(kind, map[string]PropertySpec{
+		"url":   {Path: "{.status.url}"},
+		"ready": {Path: `{.status.conditions[?(@.type=="Ready")].status}`, Type: PropertyBool},
+	})
+	if err != nil {
+		t.Fatalf("RegisterUnstructured() error = %v", err)
+	}
+	if err := RegisterUnstructured(kind, nil); err == nil {
+		t.Errorf("RegisterUnstructured() registered %s twice", kind)
+	}
+	if err := RegisterUnstructured("Service", nil); err == nil {
+		t.Errorf("RegisterUnstructured() replaced the built-in Service extractor")
+	}
+
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "serving.knative.dev/v1",
+		"kind":       "Service",
+		"metadata": map[string]interface{}{
+			"name":      "hello",
+			"namespace": "default",
+			"uid":       "uid-1",
+			"ownerReferences": []interface{}{
+				map[string]interface{}{"apiVersion": "serving.knative.dev/v1", "kind": "Service", "name": "parent", "uid": "uid-0"},
+				map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMap", "name": "settings", "uid": "uid-2"},
+			},
+		},
+		"status": map[string]interface{}{
+			"url":        "http://hello.default.example.com",
+			"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "True"}},
+		},
+	}}
+
+	for name, form := range map[string]interface{}{"unstructured": obj, "map": obj.Object} {
+		t.Run(name, func(t *testing.T) {
+			result, err := Extract(kind, form)
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+
+			want := graph.EntityKey{Name: "hello", Namespace: "default", Type: kind}
+			if result.Node.Key != want {
+				t.Errorf("node key = %v, want %v", result.Node.Key, want)
+			}
+			if result.Node.UID != "uid-1" || result.Node.APIVersion != "serving.knative.dev/v1" {
+				t.Errorf("node identity = %q, %q", result.Node.UID, result.Node.APIVersion)
+			}
+			wantProperties := map[string]interface{}{"url": "http://hello.default.example.com", "ready": true}
+			if !reflect.DeepEqual(result.Node.Properties, wantProperties) {
+				t.Errorf("properties = %v, want %v", result.Node.Properties, wantProperties)
+			}
+
+			var targets []graph.EntityKey
+			for _, rel := range result.Relationships {
+				targets = append(targets, rel.Target)
+			}
+			wantTargets := []graph.EntityKey{
+				{Name: "parent", Namespace: "default", Type: kind},
+				{Name: "settings", Namespace: "default", Type: "ConfigMap"},
+				{Name: "default", Type: "Namespace"},
+			}
+			if !reflect.DeepEqual(targets, wantTargets) {
+				t.Errorf("relationship targets = %v, want %v", targets, wantTargets)
+			}
+		})
+	}
+
+	if _, err := Extract(kind, "hello"); err == nil {
+		t.Errorf("Extract() accepted a string")
+	}
+}
+
+func TestRulesString(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []rbacv1.PolicyRule
+		want  string
+	}{
+		{"no rules", nil, ""},
+		{
+			name:  "core group",
+			rules: []rbacv1.PolicyRule{{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods", "pods/log"}}},
+			want:  "get,list pods,pods/log",
+		},
+		{
+			name:  "named groups",
+			rules: []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{"", "apps"}, Resources: []string{"deployments"}}},
+			want:  "* deployments,apps/deployments",
+		},
+		{
+			name:  "wildcard group",
+			rules: []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{"*"}, Resources: []string{"*"}}},
+			want:  "get */*",
+		},
+		{
+			name:  "resource names",
+			rules: []rbacv1.PolicyRule{{Verbs: []string{"get", "update"}, APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"app-config", "feature-flags"}}},
+			want:  "get,update configmaps[app-config,feature-flags]",
+		},
+		{
+			name:  "non-resource URLs",
+			rules: []rbacv1.PolicyRule{{Verbs: []string{"get"}, NonResourceURLs: []string{"/healthz", "/metrics"}}},
+			want:  "get /healthz,/metrics",
+		},
+		{
+			name: "multiple rules",
+			rules: []rbacv1.PolicyRule{
+				{Verbs: []string{"get", "list"}, APIGroups: []string{"", "apps"}, Resources: []string{"pods", "deployments"}},
+				{Verbs: []string{"get"}, NonResourceURLs: []string{"/healthz"}},
+			},
+			want: "get,list pods,deployments,apps/pods,apps/deployments; get /healthz",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rulesString(tt.rules); got != tt.want {
+				t.Errorf("rulesString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPodSpecRelationships(t *testing.T) {
+	yes, no := true, false
+	pod := graph.EntityKey{Name: "web-0", Namespace: "default", Type: "Pod"}
+	uses := func(kind, name, via string, optional bool) graph.GraphRelationship {
+		return graph.GraphRelationship{
+			Source:           pod,
+			Target:           graph.EntityKey{Name: name, Namespace: "default", Type: kind},
+			RelationshipType: "uses",
+			Properties:       map[string]string{"via": via, "optional": fmt.Sprintf("%t", optional)},
+		}
+	}
+	configMapVolume := func(name string, optional *bool) corev1.Volume {
+		return corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Optional:             optional,
+		}}}
+	}
+	secretKeyEnv := func(name string, optional *bool) corev1.EnvVar {
+		return corev1.EnvVar{Name: "PASSWORD", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: name},
+			Key:                  "password",
+			Optional:             optional,
+		}}}
+	}
+
+	tests := []struct {
+		name string
+		spec corev1.PodSpec
+		want []graph.GraphRelationship
+	}{
+		{
+			name: "references merged into one relationship",
+			spec: corev1.PodSpec{
+				Volumes: []corev1.Volume{configMapVolume("settings", nil)},
+				Containers: []corev1.Container{{
+					Name: "web",
+					EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "settings"},
+					}}},
+					Env: []corev1.EnvVar{{Name: "MODE", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "settings"},
+						Key:                  "mode",
+					}}}},
+				}},
+			},
+			want: []graph.GraphRelationship{uses("ConfigMap", "settings", "env,envFrom,volume", false)},
+		},
+		{
+			name: "optional only if every reference is",
+			spec: corev1.PodSpec{
+				Volumes: []corev1.Volume{configMapVolume("all-optional", &yes), configMapVolume("some-optional", &yes)},
+				Containers: []corev1.Container{{
+					Name: "web",
+					EnvFrom: []corev1.EnvFromSource{
+						{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "all-optional"}, Optional: &yes}},
+						{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "some-optional"}, Optional: &no}},
+					},
+				}},
+			},
+			want: []graph.GraphRelationship{
+				uses("ConfigMap", "all-optional", "envFrom,volume", true),
+				uses("ConfigMap", "some-optional", "envFrom,volume", false),
+			},
+		},
+		{
+			name: "projected volumes",
+			spec: corev1.PodSpec{
+				Volumes: []corev1.Volume{{Name: "bundle", VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{
+						{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "ca-bundle"}}},
+						{Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "tls"}, Optional: &yes}},
+						{ServiceAccountToken: &corev1.ServiceAccountTokenProjection{Path: "token"}},
+					},
+				}}}},
+			},
+			want: []graph.GraphRelationship{
+				uses("ConfigMap", "ca-bundle", "projected", false),
+				uses("Secret", "tls", "projected", true),
+			},
+		},
+		{
+			name: "init and ephemeral containers",
+			spec: corev1.PodSpec{
+				InitContainers: []corev1.Container{{Name: "migrate", Env: []corev1.EnvVar{secretKeyEnv("db-credentials", nil)}}},
+				Containers:     []corev1.Container{{Name: "web", Env: []corev1.EnvVar{{Name: "PLAIN", Value: "value"}}}},
+				EphemeralContainers: []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+					Name: "debug",
+					Env:  []corev1.EnvVar{secretKeyEnv("debug-token", &yes)},
+				}}},
+			},
+			want: []graph.GraphRelationship{
+				uses("Secret", "db-credentials", "env", false),
+				uses("Secret", "debug-token", "env", true),
+			},
+		},
+		{
+			name: "image pull secrets",
+			spec: corev1.PodSpec{
+				Volumes:          []corev1.Volume{{Name: "registry", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "registry", Optional: &yes}}}},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: "registry"}, {Name: "mirror"}},
+			},
+			want: []graph.GraphRelationship{
+				uses("Secret", "registry", "imagePullSecret,volume", false),
+				uses("Secret", "mirror", "imagePullSecret", false),
+			},
+		},
+		{
+			name: "empty names skipped",
+			spec: corev1.PodSpec{
+				Volumes:          []corev1.Volume{configMapVolume("", nil)},
+				Containers:       []corev1.Container{{Name: "web", Env: []corev1.EnvVar{secretKeyEnv("", nil)}}},
+				ImagePullSecrets: []corev1.LocalObjectReference{{Name: ""}},
+			},
+			want: []graph.GraphRelationship{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := podSpecRelationships(pod, &tt.spec); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("podSpecRelationships() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// relationshipsOf returns the relationships of a result with one of the given types
+func relationshipsOf(result *Result, relationshipTypes ...string) []graph.GraphRelationship {
+	var rels []graph.GraphRelationship
+	for _, rel := range result.Relationships {
+		for _, relationshipType := range relationshipTypes {
+			if rel.RelationshipType == relationshipType {
+				rels = append(rels, rel)
+			}
+		}
+	}
+	return rels
+}
+
+func TestVolumeRelationships(t *testing.T) {
+	pod := graph.EntityKey{Name: "web-0", Namespace: "default", Type: "Pod"}
+	mounts := func(claim, volume string, readOnly bool) graph.GraphRelationship {
+		return graph.GraphRelationship{
+			Source:           pod,
+			Target:           graph.EntityKey{Name: claim, Namespace: "default", Type: "PersistentVolumeClaim"},
+			RelationshipType: "mounts",
+			Properties:       map[string]string{"volume": volume, "readOnly": fmt.Sprintf("%t", readOnly)},
+		}
+	}
+	spec := &corev1.PodSpec{Volumes: []corev1.Volume{
+		{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data-web-0"}}},
+		{Name: "shared", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "shared", ReadOnly: true}}},
+		{Name: "scratch", VolumeSource: corev1.VolumeSource{Ephemeral: &corev1.EphemeralVolumeSource{}}},
+		{Name: "cache", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+	}}
+	want := []graph.GraphRelationship{
+		mounts("data-web-0", "data", false),
+		mounts("shared", "shared", true),
+		mounts("web-0-scratch", "scratch", false),
+	}
+
+	if got := volumeRelationships(pod, spec); !reflect.DeepEqual(got, want) {
+		t.Errorf("volumeRelationships() = %+v, want %+v", got, want)
+	}
+}
+
+func TestPersistentVolumeClaim(t *testing.T) {
+	key := graph.EntityKey{Name: "data", Namespace: "default", Type: "PersistentVolumeClaim"}
+	empty, fast := "", "fast"
+
+	tests := []struct {
+		name              string
+		spec              corev1.PersistentVolumeClaimSpec
+		phase             corev1.PersistentVolumeClaimPhase
+		wantProperties    map[string]interface{}
+		wantRelationships []graph.GraphRelationship
+	}{
+		{
+			name:  "pending claim of the default class",
+			spec:  corev1.PersistentVolumeClaimSpec{AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}},
+			phase: corev1.ClaimPending,
+			wantProperties: map[string]interface{}{
+				"phase": "Pending", "requested": "", "capacity": "", "accessModes": "ReadWriteOnce", "storageClassName": "", "volumeMode": "",
+			},
+		},
+		{
+			name:  "claim without a class",
+			spec:  corev1.PersistentVolumeClaimSpec{StorageClassName: &empty, AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce, corev1.ReadOnlyMany}},
+			phase: corev1.ClaimPending,
+			wantProperties: map[string]interface{}{
+				"phase": "Pending", "requested": "", "capacity": "", "accessModes": "ReadWriteOnce,ReadOnlyMany", "storageClassName": "", "volumeMode": "",
+			},
+		},
+		{
+			name:  "bound claim",
+			spec:  corev1.PersistentVolumeClaimSpec{StorageClassName: &fast, VolumeName: "pv-1", AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOncePod}},
+			phase: corev1.ClaimBound,
+			wantProperties: map[string]interface{}{
+				"phase": "Bound", "requested": "", "capacity": "", "accessModes": "ReadWriteOncePod", "storageClassName": "fast", "volumeMode": "",
+			},
+			wantRelationships: []graph.GraphRelationship{{
+				Source:           key,
+				Target:           graph.EntityKey{Name: "pv-1", Type: "PersistentVolume"},
+				RelationshipType: "bound_to",
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Extract("PersistentVolumeClaim", &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "default"},
+				Spec:       tt.spec,
+				Status:     corev1.PersistentVolumeClaimStatus{Phase: tt.phase},
+			})
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if !reflect.DeepEqual(result.Node.Properties, tt.wantProperties) {
+				t.Errorf("properties = %v, want %v", result.Node.Properties, tt.wantProperties)
+			}
+			if got := relationshipsOf(result, "bound_to", "provisioned_by"); !reflect.DeepEqual(got, tt.wantRelationships) {
+				t.Errorf("relationships = %+v, want %+v", got, tt.wantRelationships)
+			}
+		})
+	}
+}
+
+func TestPersistentVolumeRelationships(t *testing.T) {
+	key := graph.EntityKey{Name: "pv-1", Type: "PersistentVolume"}
+	rel := func(target graph.EntityKey, relationshipType string) graph.GraphRelationship {
+		return graph.GraphRelationship{Source: key, Target: target, RelationshipType: relationshipType}
+	}
+	node := func(name string) graph.EntityKey { return graph.EntityKey{Name: name, Type: "Node"} }
+	affinity := func(expressions ...corev1.NodeSelectorRequirement) *corev1.VolumeNodeAffinity {
+		return &corev1.VolumeNodeAffinity{Required: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{MatchExpressions: expressions}},
+		}}
+	}
+
+	tests := []struct {
+		name string
+		spec corev1.PersistentVolumeSpec
+		want []graph.GraphRelationship
+	}{
+		{name: "no class or affinity", spec: corev1.PersistentVolumeSpec{}},
+		{
+			name: "provisioned by a class",
+			spec: corev1.PersistentVolumeSpec{StorageClassName: "fast"},
+			want: []graph.GraphRelationship{rel(graph.EntityKey{Name: "fast", Type: "StorageClass"}, "provisioned_by")},
+		},
+		{
+			name: "local volume on hostnames",
+			spec: corev1.PersistentVolumeSpec{
+				StorageClassName: "local",
+				NodeAffinity: affinity(corev1.NodeSelectorRequirement{
+					Key: corev1.LabelHostname, Operator: corev1.NodeSelectorOpIn, Values: []string{"node-1", "node-2"},
+				}),
+			},
+			want: []graph.GraphRelationship{
+				rel(graph.EntityKey{Name: "local", Type: "StorageClass"}, "provisioned_by"),
+				rel(node("node-1"), "located_on"),
+				rel(node("node-2"), "located_on"),
+			},
+		},
+		{
+			name: "affinity on other labels or operators",
+			spec: corev1.PersistentVolumeSpec{
+				NodeAffinity: affinity(
+					corev1.NodeSelectorRequirement{Key: corev1.LabelTopologyZone, Operator: corev1.NodeSelectorOpIn, Values: []string{"zone-a"}},
+					corev1.NodeSelectorRequirement{Key: corev1.LabelHostname, Operator: corev1.NodeSelectorOpNotIn, Values: []string{"node-3"}},
+				),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Extract("PersistentVolume", &corev1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-1"}, Spec: tt.spec})
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if got := relationshipsOf(result, "provisioned_by", "located_on"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("relationships = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobStatus(t *testing.T) {
+	condition := func(conditionType batchv1.JobConditionType, status corev1.ConditionStatus) batchv1.JobCondition {
+		return batchv1.JobCondition{Type: conditionType, Status: status}
+	}
+
+	tests := []struct {
+		name       string
+		conditions []batchv1.JobCondition
+		want       string
+	}{
+		{"running", nil, "running"},
+		{"complete", []batchv1.JobCondition{condition(batchv1.JobComplete, corev1.ConditionTrue)}, "complete"},
+		{"failed", []batchv1.JobCondition{condition(batchv1.JobFailed, corev1.ConditionTrue)}, "failed"},
+		{"suspended", []batchv1.JobCondition{condition(batchv1.JobSuspended, corev1.ConditionTrue)}, "suspended"},
+		{"resumed", []batchv1.JobCondition{condition(batchv1.JobSuspended, corev1.ConditionFalse)}, "running"},
+		{
+			name: "completed after being resumed",
+			conditions: []batchv1.JobCondition{
+				condition(batchv1.JobSuspended, corev1.ConditionFalse),
+				condition(batchv1.JobComplete, corev1.ConditionTrue),
+			},
+			want: "complete",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Extract("Job", &batchv1.Job{
+				ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: "default"},
+				Status:     batchv1.JobStatus{Conditions: tt.conditions},
+			})
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if got := result.Node.Properties["status"]; got != tt.want {
+				t.Errorf("status = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceProperties(t *testing.T) {
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "team-a",
+			Labels: map[string]string{
+				"kubernetes.io/metadata.name": "team-a",
+				"team":                        "a",
+			},
+			Annotations: map[string]string{
+				"owner": "platform@example.com",
+				"scheduler.alpha.kubernetes.io/node-selector": "pool=a",
+				corev1.LastAppliedConfigAnnotation:            `{"apiVersion":"v1","kind":"Namespace"}`,
+			},
+		},
+		Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+	}
+	want := map[string]interface{}{
+		"phase":                             "Active",
+		"label.kubernetes.io/metadata.name": "team-a",
+		"label.team":                        "a",
+		"annotation.owner":                  "platform@example.com",
+		"annotation.scheduler.alpha.kubernetes.io/node-selector": "pool=a",
+	}
+
+	result, err := Extract("Namespace", namespace)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if !reflect.DeepEqual(result.Node.Properties, want) {
+		t.Errorf("properties = %v, want %v", result.Node.Properties, want)
+	}
+}
+
+func TestScaleTargetType(t *testing.T) {
+	tests := []struct {
+		apiVersion string
+		kind       string
+		want       string
+	}{
+		{"apps/v1", "Deployment", "Deployment"},
+		{"apps/v1", "StatefulSet", "StatefulSet"},
+		{"argoproj.io/v1alpha1", "Rollout", "Rollout.argoproj.io"},
+		{"serving.knative.dev/v1", "Service", "Service.serving.knative.dev"},
+		{"", "Deployment", "Deployment"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.apiVersion+"/"+tt.kind, func(t *testing.T) {
+			result, err := Extract("HorizontalPodAutoscaler", &autoscalingv2.HorizontalPodAutoscaler{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+					ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{APIVersion: tt.apiVersion, Kind: tt.kind, Name: "web"},
+				},
+			})
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			want := []graph.GraphRelationship{{
+				Source:           graph.EntityKey{Name: "web", Namespace: "default", Type: "HorizontalPodAutoscaler"},
+				Target:           graph.EntityKey{Name: "web", Namespace: "default", Type: tt.want},
+				RelationshipType: "scales",
+				Properties:       map[string]string{"apiVersion": tt.apiVersion},
+			}}
+			if got := relationshipsOf(result, "scales"); !reflect.DeepEqual(got, want) {
+				t.Errorf("scales = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestMetricString(t *testing.T) {
+	utilization := int32(80)
+	averageValue := resource.MustParse("500m")
+	value := resource.MustParse("100")
+	utilizationTarget := autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType, AverageUtilization: &utilization}
+	averageValueTarget := autoscalingv2.MetricTarget{Type: autoscalingv2.AverageValueMetricType, AverageValue: &averageValue}
+	valueTarget := autoscalingv2.MetricTarget{Type: autoscalingv2.ValueMetricType, Value: &value}
+
+	tests := []struct {
+		name   string
+		metric autoscalingv2.MetricSpec
+		want   string
+	}{
+		{
+			name:   "resource",
+			metric: autoscalingv2.MetricSpec{Resource: &autoscalingv2.ResourceMetricSource{Name: corev1.ResourceCPU, Target: utilizationTarget}},
+			want:   "resource:cpu:Utilization=80",
+		},
+		{
+			name: "container resource",
+			metric: autoscalingv2.MetricSpec{ContainerResource: &autoscalingv2.ContainerResourceMetricSource{
+				Name: corev1.ResourceMemory, Container: "web", Target: averageValueTarget,
+			}},
+			want: "containerResource:web/memory:AverageValue=500m",
+		},
+		{
+			name: "pods",
+			metric: autoscalingv2.MetricSpec{Pods: &autoscalingv2.PodsMetricSource{
+				Metric: autoscalingv2.MetricIdentifier{Name: "requests_per_second"}, Target: averageValueTarget,
+			}},
+			want: "pods:requests_per_second:AverageValue=500m",
+		},
+		{
+			name: "object",
+			metric: autoscalingv2.MetricSpec{Object: &autoscalingv2.ObjectMetricSource{
+				DescribedObject: autoscalingv2.CrossVersionObjectReference{APIVersion: "networking.k8s.io/v1", Kind: "Ingress", Name: "web"},
+				Metric:          autoscalingv2.MetricIdentifier{Name: "requests"},
+				Target:          valueTarget,
+			}},
+			want: "object:Ingress/web/requests:Value=100",
+		},
+		{
+			name: "external",
+			metric: autoscalingv2.MetricSpec{External: &autoscalingv2.ExternalMetricSource{
+				Metric: autoscalingv2.MetricIdentifier{Name: "queue_depth"}, Target: valueTarget,
+			}},
+			want: "external:queue_depth:Value=100",
+		},
+		{
+			name:   "target without a value",
+			metric: autoscalingv2.MetricSpec{Resource: &autoscalingv2.ResourceMetricSource{Name: corev1.ResourceCPU, Target: autoscalingv2.MetricTarget{Type: autoscalingv2.UtilizationMetricType}}},
+			want:   "resource:cpu:Utilization=",
+		},
+		{name: "no source", metric: autoscalingv2.MetricSpec{}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := metricString(tt.metric); got != tt.want {
+				t.Errorf("metricString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRolloutStatus(t *testing.T) {
+	replicas := int32(3)
+	statefulSet := func(generation int64, status appsv1.StatefulSetStatus) func() (string, interface{}) {
+		return func() (string, interface{}) {
+			return "StatefulSet", &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", Generation: generation},
+				Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+				Status:     status,
+			}
+		}
+	}
+	statefulSetStatus := func(observedGeneration int64, updated, ready int32, currentRevision, updateRevision string) appsv1.StatefulSetStatus {
+		return appsv1.StatefulSetStatus{
+			ObservedGeneration: observedGeneration,
+			UpdatedReplicas:    updated,
+			ReadyReplicas:      ready,
+			CurrentRevision:    currentRevision,
+			UpdateRevision:     updateRevision,
+		}
+	}
+	daemonSet := func(generation int64, status appsv1.DaemonSetStatus) func() (string, interface{}) {
+		return func() (string, interface{}) {
+			return "DaemonSet", &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "default", Generation: generation},
+				Status:     status,
+			}
+		}
+	}
+	daemonSetStatus := func(observedGeneration int64, desired, updated, available int32) appsv1.DaemonSetStatus {
+		return appsv1.DaemonSetStatus{
+			ObservedGeneration:     observedGeneration,
+			DesiredNumberScheduled: desired,
+			UpdatedNumberScheduled: updated,
+			NumberAvailable:        available,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		object func() (string, interface{})
+		want   string
+	}{
+		{"statefulset complete", statefulSet(2, statefulSetStatus(2, 3, 3, "web-2", "web-2")), "complete"},
+		{"statefulset stale observedGeneration", statefulSet(3, statefulSetStatus(2, 3, 3, "web-2", "web-2")), "progressing"},
+		{"statefulset updated < desired", statefulSet(2, statefulSetStatus(2, 2, 3, "web-1", "web-2")), "progressing"},
+		{"statefulset ready < desired", statefulSet(2, statefulSetStatus(2, 3, 2, "web-2", "web-2")), "progressing"},
+		{"statefulset revision not yet current", statefulSet(2, statefulSetStatus(2, 3, 3, "web-1", "web-2")), "progressing"},
+
+		{"daemonset complete", daemonSet(2, daemonSetStatus(2, 3, 3, 3)), "complete"},
+		{"daemonset stale observedGeneration", daemonSet(3, daemonSetStatus(2, 3, 3, 3)), "progressing"},
+		{"daemonset updated < desired", daemonSet(2, daemonSetStatus(2, 3, 2, 3)), "progressing"},
+		{"daemonset available < desired", daemonSet(2, daemonSetStatus(2, 3, 3, 2)), "progressing"},
+		{"daemonset without nodes", daemonSet(1, daemonSetStatus(1, 0, 0, 0)), "complete"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, obj := tt.object()
+			result, err := Extract(kind, obj)
+			if err != nil {
+				t.Fatalf("Extract() error = %v", err)
+			}
+			if got := result.Node.Properties["rolloutStatus"]; got != tt.want {
+				t.Errorf("rolloutStatus = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package extractor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultRegistry is the registry of image references that do not name one,
+// and legacyDefaultRegistry the other name Docker Hub is referred to by
+const (
+	defaultRegistry       = "docker.io"
+	legacyDefaultRegistry = "index.docker.io"
+)
+
+// imageReference is a container image reference split into its parts
+type imageReference struct {
+	registry   string
+	repository string
+	tag        string
+	digest     string
+}
+
+// parseImageReference splits a container image reference such as
+// nginx:1.25 or ghcr.io/org/app@sha256:... into its parts, normalized the
+// way container runtimes resolve them: the registry defaults to docker.io,
+// index.docker.io is taken to be docker.io, single-component Docker Hub
+// repositories are under library/, and the tag defaults to latest unless the
+// image is pinned to a digest.
+func parseImageReference(image string) imageReference {
+	var ref imageReference
+
+	if i := strings.Index(image, "@"); i >= 0 {
+		image, ref.digest = image[:i], image[i+1:]
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		image, ref.tag = image[:i], image[i+1:]
+	}
+
+	// The first component is a registry only if it looks like a host name
+	ref.registry = defaultRegistry
+	if i := strings.Index(image, "/"); i >= 0 {
+		if host := image[:i]; strings.ContainsAny(host, ".:") || host == "localhost" {
+			ref.registry, image = host, image[i+1:]
+		}
+	}
+	if ref.registry == legacyDefaultRegistry {
+		ref.registry = defaultRegistry
+	}
+	if ref.registry == defaultRegistry && !strings.Contains(image, "/") {
+		image = "library/" + image
+	}
+	ref.repository = image
+
+	if ref.tag == "" && ref.digest == "" {
+		ref.tag = "latest"
+	}
+	return ref
+}
+
+// String formats the reference in its normalized form
+func (r imageReference) String() string {
+	s := r.registry + "/" + r.repository
+	if r.tag != "" {
+		s += ":" + r.tag
+	}
+	if r.digest != "" {
+		s += "@" + r.digest
+	}
+	return s
+}
+
+// node returns the Image node for the reference
+func (r imageReference) node() graph.GraphNode {
+	return graph.GraphNode{
+		Key: graph.EntityKey{Name: r.String(), Type: "Image"},
+		Properties: map[string]interface{}{
+			"registry":   r.registry,
+			"repository": r.repository,
+			"tag":        r.tag,
+			"digest":     r.digest,
+		},
+		Revision: 1,
+	}
+}
+
+// imageRelationships returns a runs_image relationship from key to the
+// image of each container in spec, along with the Image nodes, which have no
+// object of their own. digests maps container names to the digest their
+// image was resolved to; an image without a digest in its reference is
+// pinned to the resolved one, so a tag that moved is told apart, and the
+// pinned image is related to the reference as written by a resolved_from
+// relationship, which joins it to the workloads deployed by that reference.
+func imageRelationships(key graph.EntityKey, spec *corev1.PodSpec, digests map[string]string) ([]graph.GraphNode, []graph.GraphRelationship) {
+	var related []graph.GraphNode
+	var rels []graph.GraphRelationship
+	seen := make(map[graph.EntityKey]struct{})
+	resolved := make(map[graph.EntityKey]struct{})
+
+	addNode := func(node graph.GraphNode) {
+		if _, ok := seen[node.Key]; !ok {
+			seen[node.Key] = struct{}{}
+			related = append(related, node)
+		}
+	}
+
+	add := func(name, image string, init, ephemeral bool) {
+		if image == "" {
+			return
+		}
+		ref := parseImageReference(image)
+		node := ref.node()
+		if ref.digest == "" && digests[name] != "" {
+			unpinned := node
+			ref.digest = digests[name]
+			node = ref.node()
+			addNode(unpinned)
+			if _, ok := resolved[node.Key]; !ok {
+				resolved[node.Key] = struct{}{}
+				rels = append(rels, graph.GraphRelationship{
+					Source:           node.Key,
+					Target:           unpinned.Key,
+					RelationshipType: "resolved_from",
+					Properties:       map[string]string{},
+				})
+			}
+		}
+		addNode(node)
+		rels = append(rels, graph.GraphRelationship{
+			Source:           key,
+			Target:           node.Key,
+			RelationshipType: "runs_image",
+			Qualifier:        name,
+			Properties: map[string]string{
+				"container": name,
+				"init":      fmt.Sprintf("%t", init),
+				"ephemeral": fmt.Sprintf("%t", ephemeral),
+			},
+		})
+	}
+
+	for _, container := range spec.InitContainers {
+		add(container.Name, container.Image, true, false)
+	}
+	for _, container := range spec.Containers {
+		add(container.Name, container.Image, false, false)
+	}
+	for _, container := range spec.EphemeralContainers {
+		add(container.Name, container.Image, false, true)
+	}
+
+	return related, rels
+}
+
+// containerDigests maps the name of each container of a pod to the digest
+// of the image it is running, for the containers whose runtime reported one
+func containerDigests(status *corev1.PodStatus) map[string]string {
+	digests := make(map[string]string)
+	for _, statuses := range [][]corev1.ContainerStatus{status.InitContainerStatuses, status.ContainerStatuses, status.EphemeralContainerStatuses} {
+		for _, s := range statuses {
+			if digest := imageIDDigest(s.ImageID); digest != "" {
+				digests[s.Name] = digest
+			}
+		}
+	}
+	return digests
+}
+
+// imageIDDigest returns the digest of the image a container runtime
+// reports having pulled, such as docker-pullable://nginx@sha256:..., or an
+// empty string if it only reports the local image ID
+func imageIDDigest(imageID string) string {
+	if i := strings.Index(imageID, "@"); i >= 0 {
+		return imageID[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,195 @@
+package extractor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	"github.com/AdityaaMK/kubernetes-scraper/selector"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// legacyIngressClassAnnotation selects an ingress class before spec.ingressClassName existed
+	legacyIngressClassAnnotation = "kubernetes.io/ingress.class"
+	// defaultIngressClassAnnotation marks the IngressClass used when an Ingress names none
+	defaultIngressClassAnnotation = "ingressclass.kubernetes.io/is-default-class"
+	// defaultBackendQualifier qualifies the route to an Ingress's default
+	// backend. Rule routes are qualified by host and path separated by a
+	// space, which neither can contain, so they never collide with it.
+	defaultBackendQualifier = "default-backend"
+)
+
+func init() {
+	register(typedExtractor[networkingv1.Ingress]{kind: "Ingress", extract: extractIngress})
+	register(typedExtractor[networkingv1.IngressClass]{kind: "IngressClass", extract: extractIngressClass})
+	register(typedExtractor[networkingv1.NetworkPolicy]{kind: "NetworkPolicy", extract: extractNetworkPolicy})
+}
+
+func extractIngress(o *networkingv1.Ingress) *Result {
+	key := graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "Ingress"}
+	className := IngressClassName(o)
+
+	var hosts []string
+	for _, rule := range o.Spec.Rules {
+		if rule.Host != "" {
+			hosts = append(hosts, rule.Host)
+		}
+	}
+	var addresses []string
+	for _, ingress := range o.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			addresses = append(addresses, ingress.IP)
+		}
+		if ingress.Hostname != "" {
+			addresses = append(addresses, ingress.Hostname)
+		}
+	}
+
+	result := &Result{
+		Node: graph.GraphNode{
+			Key: key,
+			Properties: map[string]interface{}{
+				"ingressClassName": className,
+				"hosts":            strings.Join(hosts, ","),
+				"addresses":        strings.Join(addresses, ","),
+			},
+			Revision: 1,
+		},
+	}
+
+	// Ingress -> Service relationships, one per host/path rule
+	if backend := o.Spec.DefaultBackend; backend != nil && backend.Service != nil {
+		result.Relationships = append(result.Relationships, ingressRoute(key, defaultBackendQualifier, "*", "", "", backend.Service))
+	}
+	for _, rule := range o.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		host := rule.Host
+		if host == "" {
+			host = "*"
+		}
+		for _, path := range rule.HTTP.Paths {
+			if path.Backend.Service == nil {
+				continue
+			}
+			pathType := ""
+			if path.PathType != nil {
+				pathType = string(*path.PathType)
+			}
+			result.Relationships = append(result.Relationships, ingressRoute(key, host+" "+path.Path, host, path.Path, pathType, path.Backend.Service))
+		}
+	}
+
+	// Ingress -> Secret relationships for TLS certificates
+	for _, tls := range o.Spec.TLS {
+		if tls.SecretName == "" {
+			continue
+		}
+		result.Relationships = append(result.Relationships, graph.GraphRelationship{
+			Source:           key,
+			Target:           graph.EntityKey{Name: tls.SecretName, Namespace: o.Namespace, Type: "Secret"},
+			RelationshipType: "terminates_tls",
+			Properties: map[string]string{
+				"hosts": strings.Join(tls.Hosts, ","),
+			},
+		})
+	}
+
+	// Ingress -> IngressClass relationship. An Ingress naming no class uses
+	// the default class, which depends on other objects and is related to
+	// it outside the extractor.
+	if className != "" {
+		result.Relationships = append(result.Relationships, IngressClassRelationship(key, className, false))
+	}
+
+	return result
+}
+
+// IngressClassName returns the class an Ingress names through
+// spec.ingressClassName or the legacy annotation, or "" if it names none
+func IngressClassName(o *networkingv1.Ingress) string {
+	if o.Spec.IngressClassName != nil {
+		return *o.Spec.IngressClassName
+	}
+	return o.Annotations[legacyIngressClassAnnotation]
+}
+
+// IsDefaultIngressClass reports whether an IngressClass is marked as the
+// class of Ingresses that name none
+func IsDefaultIngressClass(o *networkingv1.IngressClass) bool {
+	return o.Annotations[defaultIngressClassAnnotation] == "true"
+}
+
+// IngressClassRelationship returns the uses_class relationship from an
+// Ingress to its class, recording whether the class was named or is the default
+func IngressClassRelationship(key graph.EntityKey, className string, isDefault bool) graph.GraphRelationship {
+	return graph.GraphRelationship{
+		Source:           key,
+		Target:           graph.EntityKey{Name: className, Type: "IngressClass"},
+		RelationshipType: "uses_class",
+		Properties: map[string]string{
+			"default": fmt.Sprintf("%t", isDefault),
+		},
+	}
+}
+
+// ingressRoute returns a routes_to relationship for a single host/path rule
+// or the default backend. The qualifier keeps several routes to the same
+// Service apart.
+func ingressRoute(key graph.EntityKey, qualifier, host, path, pathType string, backend *networkingv1.IngressServiceBackend) graph.GraphRelationship {
+	port := backend.Port.Name
+	if backend.Port.Number != 0 {
+		port = fmt.Sprintf("%d", backend.Port.Number)
+	}
+
+	return graph.GraphRelationship{
+		Source:           key,
+		Target:           graph.EntityKey{Name: backend.Name, Namespace: key.Namespace, Type: "Service"},
+		RelationshipType: "routes_to",
+		Qualifier:        qualifier,
+		Properties: map[string]string{
+			"host":     host,
+			"path":     path,
+			"pathType": pathType,
+			"port":     port,
+		},
+	}
+}
+
+func extractIngressClass(o *networkingv1.IngressClass) *Result {
+	return &Result{
+		Node: graph.GraphNode{
+			Key: graph.EntityKey{Name: o.Name, Type: "IngressClass"},
+			Properties: map[string]interface{}{
+				"controller": o.Spec.Controller,
+				"isDefault":  IsDefaultIngressClass(o),
+			},
+			Revision: 1,
+		},
+	}
+}
+
+// extractNetworkPolicy records which directions a NetworkPolicy restricts
+// and its pod selector. Its applies_to and reachability relationships depend
+// on pod and namespace labels, so they are maintained from those events
+// rather than extracted here.
+func extractNetworkPolicy(o *networkingv1.NetworkPolicy) *Result {
+	ingress, egress := selector.NetworkPolicyTypes(o)
+
+	return &Result{
+		Node: graph.GraphNode{
+			Key: graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "NetworkPolicy"},
+			Properties: map[string]interface{}{
+				"podSelector":  metav1.FormatLabelSelector(&o.Spec.PodSelector),
+				"ingress":      ingress,
+				"egress":       egress,
+				"ingressRules": len(o.Spec.Ingress),
+				"egressRules":  len(o.Spec.Egress),
+			},
+			Revision: 1,
+		},
+	}
+}
@@ -0,0 +1,127 @@
+package extractor
+
+import (
+	"fmt"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// podSpecReference is a reference from a pod spec to another object in the same namespace
+type podSpecReference struct {
+	kind     string
+	name     string
+	via      string
+	optional bool
+}
+
+// podSpecReferences returns every ConfigMap and Secret referenced by a pod
+// spec, including references from init and ephemeral containers
+func podSpecReferences(spec *corev1.PodSpec) []podSpecReference {
+	var refs []podSpecReference
+
+	for _, volume := range spec.Volumes {
+		if volume.ConfigMap != nil {
+			refs = append(refs, podSpecReference{kind: "ConfigMap", name: volume.ConfigMap.Name, via: "volume", optional: isOptional(volume.ConfigMap.Optional)})
+		}
+		if volume.Secret != nil {
+			refs = append(refs, podSpecReference{kind: "Secret", name: volume.Secret.SecretName, via: "volume", optional: isOptional(volume.Secret.Optional)})
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					refs = append(refs, podSpecReference{kind: "ConfigMap", name: source.ConfigMap.Name, via: "projected", optional: isOptional(source.ConfigMap.Optional)})
+				}
+				if source.Secret != nil {
+					refs = append(refs, podSpecReference{kind: "Secret", name: source.Secret.Name, via: "projected", optional: isOptional(source.Secret.Optional)})
+				}
+			}
+		}
+	}
+
+	for _, container := range spec.InitContainers {
+		refs = append(refs, envReferences(container.Env, container.EnvFrom)...)
+	}
+	for _, container := range spec.Containers {
+		refs = append(refs, envReferences(container.Env, container.EnvFrom)...)
+	}
+	for _, container := range spec.EphemeralContainers {
+		refs = append(refs, envReferences(container.Env, container.EnvFrom)...)
+	}
+
+	for _, pullSecret := range spec.ImagePullSecrets {
+		refs = append(refs, podSpecReference{kind: "Secret", name: pullSecret.Name, via: "imagePullSecret"})
+	}
+
+	return refs
+}
+
+// envReferences returns the ConfigMaps and Secrets referenced by a container's environment
+func envReferences(env []corev1.EnvVar, envFrom []corev1.EnvFromSource) []podSpecReference {
+	var refs []podSpecReference
+
+	for _, source := range envFrom {
+		if source.ConfigMapRef != nil {
+			refs = append(refs, podSpecReference{kind: "ConfigMap", name: source.ConfigMapRef.Name, via: "envFrom", optional: isOptional(source.ConfigMapRef.Optional)})
+		}
+		if source.SecretRef != nil {
+			refs = append(refs, podSpecReference{kind: "Secret", name: source.SecretRef.Name, via: "envFrom", optional: isOptional(source.SecretRef.Optional)})
+		}
+	}
+
+	for _, variable := range env {
+		if variable.ValueFrom == nil {
+			continue
+		}
+		if ref := variable.ValueFrom.ConfigMapKeyRef; ref != nil {
+			refs = append(refs, podSpecReference{kind: "ConfigMap", name: ref.Name, via: "env", optional: isOptional(ref.Optional)})
+		}
+		if ref := variable.ValueFrom.SecretKeyRef; ref != nil {
+			refs = append(refs, podSpecReference{kind: "Secret", name: ref.Name, via: "env", optional: isOptional(ref.Optional)})
+		}
+	}
+
+	return refs
+}
+
+// podSpecRelationships returns a uses relationship from key to each object
+// referenced by a pod spec. An object referenced in several ways gets a
+// single relationship listing every way in its via property; it is optional
+// only if every reference to it is optional.
+func podSpecRelationships(key graph.EntityKey, spec *corev1.PodSpec) []graph.GraphRelationship {
+	vias := make(map[graph.EntityKey]map[string]struct{})
+	optional := make(map[graph.EntityKey]bool)
+	var targets []graph.EntityKey
+	for _, ref := range podSpecReferences(spec) {
+		if ref.name == "" {
+			continue
+		}
+		target := graph.EntityKey{Name: ref.name, Namespace: key.Namespace, Type: ref.kind}
+		if _, ok := vias[target]; !ok {
+			vias[target] = make(map[string]struct{})
+			optional[target] = true
+			targets = append(targets, target)
+		}
+		vias[target][ref.via] = struct{}{}
+		optional[target] = optional[target] && ref.optional
+	}
+
+	rels := make([]graph.GraphRelationship, 0, len(targets))
+	for _, target := range targets {
+		rels = append(rels, graph.GraphRelationship{
+			Source:           key,
+			Target:           target,
+			RelationshipType: "uses",
+			Properties: map[string]string{
+				"via":      JoinSet(vias[target]),
+				"optional": fmt.Sprintf("%t", optional[target]),
+			},
+		})
+	}
+	return rels
+}
+
+// isOptional dereferences an optional flag, which defaults to false
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
@@ -0,0 +1,39 @@
+package extractor
+
+import (
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func init() {
+	register(typedExtractor[policyv1.PodDisruptionBudget]{kind: "PodDisruptionBudget", extract: extractPodDisruptionBudget})
+}
+
+// extractPodDisruptionBudget records a PDB's budget and current disruption
+// status. Its protects relationships to pods depend on the pods' labels, so
+// they are maintained from pod events rather than extracted here.
+func extractPodDisruptionBudget(o *policyv1.PodDisruptionBudget) *Result {
+	return &Result{
+		Node: graph.GraphNode{
+			Key: graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "PodDisruptionBudget"},
+			Properties: map[string]interface{}{
+				"minAvailable":       intOrStringValue(o.Spec.MinAvailable),
+				"maxUnavailable":     intOrStringValue(o.Spec.MaxUnavailable),
+				"disruptionsAllowed": o.Status.DisruptionsAllowed,
+				"currentHealthy":     o.Status.CurrentHealthy,
+				"desiredHealthy":     o.Status.DesiredHealthy,
+				"expectedPods":       o.Status.ExpectedPods,
+			},
+			Revision: 1,
+		},
+	}
+}
+
+// intOrStringValue formats an optional count or percentage, or returns an empty string if unset
+func intOrStringValue(v *intstr.IntOrString) string {
+	if v == nil {
+		return ""
+	}
+	return v.String()
+}
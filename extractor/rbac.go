@@ -0,0 +1,161 @@
+package extractor
+
+import (
+	"strings"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func init() {
+	register(typedExtractor[rbacv1.Role]{kind: "Role", extract: extractRole})
+	register(typedExtractor[rbacv1.ClusterRole]{kind: "ClusterRole", extract: extractClusterRole})
+	register(typedExtractor[rbacv1.RoleBinding]{kind: "RoleBinding", extract: extractRoleBinding})
+	register(typedExtractor[rbacv1.ClusterRoleBinding]{kind: "ClusterRoleBinding", extract: extractClusterRoleBinding})
+}
+
+func extractRole(o *rbacv1.Role) *Result {
+	return &Result{
+		Node: graph.GraphNode{
+			Key: graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "Role"},
+			Properties: map[string]interface{}{
+				"rules": rulesString(o.Rules),
+			},
+			Revision: 1,
+		},
+	}
+}
+
+// extractClusterRole records a ClusterRole's rules. The rules of an
+// aggregated ClusterRole are filled in by the controller from the roles its
+// aggregationRule selects.
+func extractClusterRole(o *rbacv1.ClusterRole) *Result {
+	return &Result{
+		Node: graph.GraphNode{
+			Key: graph.EntityKey{Name: o.Name, Type: "ClusterRole"},
+			Properties: map[string]interface{}{
+				"rules":      rulesString(o.Rules),
+				"aggregated": o.AggregationRule != nil,
+			},
+			Revision: 1,
+		},
+	}
+}
+
+func extractRoleBinding(o *rbacv1.RoleBinding) *Result {
+	key := graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "RoleBinding"}
+	result := &Result{
+		Node: graph.GraphNode{
+			Key:        key,
+			Properties: map[string]interface{}{},
+			Revision:   1,
+		},
+	}
+
+	// RoleBinding -> Role/ClusterRole relationship. A ClusterRole bound by a
+	// RoleBinding only grants its rules within the binding's namespace.
+	role := graph.EntityKey{Name: o.RoleRef.Name, Namespace: o.Namespace, Type: o.RoleRef.Kind}
+	if o.RoleRef.Kind == "ClusterRole" {
+		role.Namespace = ""
+	}
+	result.Relationships = append(result.Relationships, graph.GraphRelationship{
+		Source:           key,
+		Target:           role,
+		RelationshipType: "grants",
+		Properties: map[string]string{
+			"scope": o.Namespace,
+		},
+	})
+
+	// RoleBinding -> ServiceAccount/User/Group relationships
+	related, rels := subjectRelationships(key, o.Namespace, o.Subjects)
+	result.Related = related
+	result.Relationships = append(result.Relationships, rels...)
+
+	return result
+}
+
+func extractClusterRoleBinding(o *rbacv1.ClusterRoleBinding) *Result {
+	key := graph.EntityKey{Name: o.Name, Type: "ClusterRoleBinding"}
+	result := &Result{
+		Node: graph.GraphNode{
+			Key:        key,
+			Properties: map[string]interface{}{},
+			Revision:   1,
+		},
+	}
+
+	// ClusterRoleBinding -> ClusterRole relationship
+	result.Relationships = append(result.Relationships, graph.GraphRelationship{
+		Source:           key,
+		Target:           graph.EntityKey{Name: o.RoleRef.Name, Type: o.RoleRef.Kind},
+		RelationshipType: "grants",
+		Properties: map[string]string{
+			"scope": "*",
+		},
+	})
+
+	// ClusterRoleBinding -> ServiceAccount/User/Group relationships
+	related, rels := subjectRelationships(key, "", o.Subjects)
+	result.Related = related
+	result.Relationships = append(result.Relationships, rels...)
+
+	return result
+}
+
+// subjectRelationships returns binds relationships from a binding to each of
+// its subjects, along with nodes for the users and groups, which have no
+// object of their own. ServiceAccount subjects without a namespace default
+// to the binding's namespace.
+func subjectRelationships(key graph.EntityKey, namespace string, subjects []rbacv1.Subject) ([]graph.GraphNode, []graph.GraphRelationship) {
+	var related []graph.GraphNode
+	var rels []graph.GraphRelationship
+	for _, subject := range subjects {
+		target := graph.EntityKey{Name: subject.Name, Type: subject.Kind}
+		switch subject.Kind {
+		case rbacv1.ServiceAccountKind:
+			target.Namespace = subject.Namespace
+			if target.Namespace == "" {
+				target.Namespace = namespace
+			}
+		case rbacv1.UserKind, rbacv1.GroupKind:
+			related = append(related, graph.GraphNode{Key: target, Properties: map[string]interface{}{}, Revision: 1})
+		default:
+			continue
+		}
+
+		rels = append(rels, graph.GraphRelationship{
+			Source:           key,
+			Target:           target,
+			RelationshipType: "binds",
+		})
+	}
+	return related, rels
+}
+
+// rulesString summarizes policy rules as "verbs resources" entries separated
+// by semicolons, e.g. "get,list pods,apps/deployments; get /healthz".
+// Resources outside the core group are prefixed with their API group, and
+// resource names follow in brackets.
+func rulesString(rules []rbacv1.PolicyRule) string {
+	summaries := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		var targets []string
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				if group != "" {
+					resource = group + "/" + resource
+				}
+				targets = append(targets, resource)
+			}
+		}
+		targets = append(targets, rule.NonResourceURLs...)
+
+		summary := strings.Join(rule.Verbs, ",") + " " + strings.Join(targets, ",")
+		if len(rule.ResourceNames) > 0 {
+			summary += "[" + strings.Join(rule.ResourceNames, ",") + "]"
+		}
+		summaries = append(summaries, summary)
+	}
+	return strings.Join(summaries, "; ")
+}
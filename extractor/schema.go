@@ -0,0 +1,293 @@
+package extractor
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// PropertyType is the type of a schema property's value
+type PropertyType string
+
+const (
+	// PropertyString formats the matched values, comma-separated if there are several
+	PropertyString PropertyType = "string"
+	// PropertyNumber sums the matched numbers, which may also be quantities such as 500m or 1Gi
+	PropertyNumber PropertyType = "number"
+	// PropertyBool is true if every matched value is true
+	PropertyBool PropertyType = "bool"
+	// PropertyList lists the matched values
+	PropertyList PropertyType = "list"
+	// PropertyMap is a single matched object
+	PropertyMap PropertyType = "map"
+)
+
+// PropertySpec declares a node property as a JSONPath expression over the
+// object, such as {.metadata.labels}, and the type of its value. The braces
+// may be omitted, and the type defaults to string.
+type PropertySpec struct {
+	Path string       `json:"path"`
+	Type PropertyType `json:"type,omitempty"`
+}
+
+// UnmarshalJSON accepts either a PropertySpec or a bare JSONPath
+// expression, which declares a string property
+func (p *PropertySpec) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*p = PropertySpec{Path: path}
+		return nil
+	}
+
+	type spec PropertySpec
+	return json.Unmarshal(data, (*spec)(p))
+}
+
+// property is a validated PropertySpec. Its path is parsed again for every
+// object, since a parsed JSONPath keeps the state of its last evaluation and
+// objects of one kind may be extracted concurrently.
+type property struct {
+	path string
+	typ  PropertyType
+}
+
+// schemas holds the properties configured for each kind
+var schemas = map[string]map[string]property{}
+
+// AddPropertySchema adds properties to the nodes of kind, on top of those
+// its extractor records. A property with the name of a recorded one
+// replaces it. It must be called before any objects are extracted.
+func AddPropertySchema(kind string, specs map[string]PropertySpec) error {
+	if _, ok := registry[kind]; !ok {
+		return fmt.Errorf("no extractor registered for kind %s", kind)
+	}
+
+	schema, ok := schemas[kind]
+	if !ok {
+		schema = make(map[string]property, len(specs))
+		schemas[kind] = schema
+	}
+	for name, spec := range specs {
+		typ := spec.Type
+		if typ == "" {
+			typ = PropertyString
+		}
+		switch typ {
+		case PropertyString, PropertyNumber, PropertyBool, PropertyList, PropertyMap:
+		default:
+			return fmt.Errorf("%s property %s has unknown type %q", kind, name, typ)
+		}
+
+		path := spec.Path
+		if !strings.HasPrefix(path, "{") {
+			path = "{" + path + "}"
+		}
+		if _, err := parsePath(name, path); err != nil {
+			return fmt.Errorf("error parsing %s property %s: %v", kind, name, err)
+		}
+		schema[name] = property{path: path, typ: typ}
+	}
+	return nil
+}
+
+// parsePath parses the JSONPath expression of a property
+func parsePath(name, path string) (*jsonpath.JSONPath, error) {
+	parsed := jsonpath.New(name).AllowMissingKeys(true)
+	if err := parsed.Parse(path); err != nil {
+		return nil, err
+	}
+	return parsed, nil
+}
+
+// valueKinds are the kinds whose values must never reach the graph, and
+// valueFields the fields of their unstructured form that hold the values
+var (
+	valueKinds  = map[string]bool{"Secret": true, "ConfigMap": true}
+	valueFields = []string{"data", "stringData", "binaryData"}
+)
+
+// lastAppliedAnnotation is where kubectl apply records the applied object,
+// values included
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// withoutValues returns a copy of the unstructured form of a Secret or
+// ConfigMap without its values, so no schema path can match them. u itself
+// is left unchanged, as it may be an informer's cached object.
+func withoutValues(u map[string]interface{}) map[string]interface{} {
+	stripped := make(map[string]interface{}, len(u))
+	for k, v := range u {
+		stripped[k] = v
+	}
+	for _, field := range valueFields {
+		delete(stripped, field)
+	}
+
+	metadata, _ := u["metadata"].(map[string]interface{})
+	annotations, _ := metadata["annotations"].(map[string]interface{})
+	if _, ok := annotations[lastAppliedAnnotation]; ok {
+		strippedMetadata := make(map[string]interface{}, len(metadata))
+		for k, v := range metadata {
+			strippedMetadata[k] = v
+		}
+		strippedAnnotations := make(map[string]interface{}, len(annotations))
+		for k, v := range annotations {
+			strippedAnnotations[k] = v
+		}
+		delete(strippedAnnotations, lastAppliedAnnotation)
+		strippedMetadata["annotations"] = strippedAnnotations
+		stripped["metadata"] = strippedMetadata
+	}
+	return stripped
+}
+
+// addSchemaProperties sets the schema properties of kind on result's node.
+// obj is converted to its unstructured form only if kind has a schema.
+// Properties without a match, or whose values do not convert to their
+// type, are left out. Paths are never evaluated against the values of
+// Secrets and ConfigMaps.
+func addSchemaProperties(result *Result, kind string, obj interface{}) error {
+	schema, ok := schemas[kind]
+	if !ok {
+		return nil
+	}
+
+	u, ok := obj.(map[string]interface{})
+	if !ok {
+		var err error
+		if u, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj); err != nil {
+			return fmt.Errorf("error converting %s: %v", kind, err)
+		}
+	}
+	if valueKinds[kind] {
+		u = withoutValues(u)
+	}
+
+	if result.Node.Properties == nil {
+		result.Node.Properties = make(map[string]interface{}, len(schema))
+	}
+	for name, p := range schema {
+		path, err := parsePath(name, p.path)
+		if err != nil {
+			return fmt.Errorf("error parsing %s property %s: %v", kind, name, err)
+		}
+		matches, err := path.FindResults(u)
+		if err != nil {
+			return fmt.Errorf("error extracting %s property %s: %v", kind, name, err)
+		}
+		var values []interface{}
+		for _, match := range matches {
+			for _, v := range match {
+				values = append(values, reflectValue(v))
+			}
+		}
+		if value, ok := convertProperty(p.typ, values); ok {
+			result.Node.Properties[name] = value
+		}
+	}
+	return nil
+}
+
+// reflectValue unwraps a value matched by a JSONPath expression
+func reflectValue(v reflect.Value) interface{} {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	return v.Interface()
+}
+
+// convertProperty converts the values matched for a property to its type
+func convertProperty(typ PropertyType, values []interface{}) (interface{}, bool) {
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	switch typ {
+	case PropertyString:
+		formatted := make([]string, 0, len(values))
+		for _, v := range values {
+			if s, ok := v.(string); ok {
+				formatted = append(formatted, s)
+				continue
+			}
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, false
+			}
+			formatted = append(formatted, string(data))
+		}
+		return strings.Join(formatted, ","), true
+
+	case PropertyNumber:
+		var sum float64
+		for _, v := range values {
+			n, ok := number(v)
+			if !ok {
+				return nil, false
+			}
+			sum += n
+		}
+		return sum, true
+
+	case PropertyBool:
+		all := true
+		for _, v := range values {
+			b, ok := v.(bool)
+			if s, isString := v.(string); isString {
+				var err error
+				b, err = strconv.ParseBool(s)
+				ok = err == nil
+			}
+			if !ok {
+				return nil, false
+			}
+			all = all && b
+		}
+		return all, true
+
+	case PropertyList:
+		// A single list, such as {.spec.finalizers}, is the list itself
+		if list, ok := values[0].([]interface{}); ok && len(values) == 1 {
+			return list, true
+		}
+		return values, true
+
+	case PropertyMap:
+		m, ok := values[0].(map[string]interface{})
+		if !ok || len(values) > 1 {
+			return nil, false
+		}
+		return m, true
+	}
+	return nil, false
+}
+
+// number converts a matched value to a number. Strings are parsed as
+// quantities, so resource requests such as 500m convert to 0.5.
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	case string:
+		q, err := resource.ParseQuantity(n)
+		if err != nil {
+			return 0, false
+		}
+		return q.AsApproximateFloat64(), true
+	}
+	return 0, false
+}
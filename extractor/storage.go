@@ -0,0 +1,186 @@
+package extractor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+)
+
+func init() {
+	register(typedExtractor[corev1.PersistentVolumeClaim]{kind: "PersistentVolumeClaim", extract: extractPersistentVolumeClaim})
+	register(typedExtractor[corev1.PersistentVolume]{kind: "PersistentVolume", extract: extractPersistentVolume})
+	register(typedExtractor[storagev1.StorageClass]{kind: "StorageClass", extract: extractStorageClass})
+}
+
+func extractPersistentVolumeClaim(o *corev1.PersistentVolumeClaim) *Result {
+	key := graph.EntityKey{Name: o.Name, Namespace: o.Namespace, Type: "PersistentVolumeClaim"}
+
+	storageClassName := ""
+	if o.Spec.StorageClassName != nil {
+		storageClassName = *o.Spec.StorageClassName
+	}
+	volumeMode := ""
+	if o.Spec.VolumeMode != nil {
+		volumeMode = string(*o.Spec.VolumeMode)
+	}
+
+	result := &Result{
+		Node: graph.GraphNode{
+			Key: key,
+			Properties: map[string]interface{}{
+				"phase":            string(o.Status.Phase),
+				"requested":        quantityString(o.Spec.Resources.Requests, corev1.ResourceStorage),
+				"capacity":         quantityString(o.Status.Capacity, corev1.ResourceStorage),
+				"accessModes":      accessModesString(o.Spec.AccessModes),
+				"storageClassName": storageClassName,
+				"volumeMode":       volumeMode,
+			},
+			Revision: 1,
+		},
+	}
+
+	// PersistentVolumeClaim -> PersistentVolume relationship
+	if o.Spec.VolumeName != "" {
+		result.Relationships = append(result.Relationships, graph.GraphRelationship{
+			Source:           key,
+			Target:           graph.EntityKey{Name: o.Spec.VolumeName, Type: "PersistentVolume"},
+			RelationshipType: "bound_to",
+		})
+	}
+
+	return result
+}
+
+func extractPersistentVolume(o *corev1.PersistentVolume) *Result {
+	key := graph.EntityKey{Name: o.Name, Type: "PersistentVolume"}
+
+	claim := ""
+	if o.Spec.ClaimRef != nil {
+		claim = fmt.Sprintf("%s/%s", o.Spec.ClaimRef.Namespace, o.Spec.ClaimRef.Name)
+	}
+
+	result := &Result{
+		Node: graph.GraphNode{
+			Key: key,
+			Properties: map[string]interface{}{
+				"phase":            string(o.Status.Phase),
+				"capacity":         quantityString(o.Spec.Capacity, corev1.ResourceStorage),
+				"accessModes":      accessModesString(o.Spec.AccessModes),
+				"reclaimPolicy":    string(o.Spec.PersistentVolumeReclaimPolicy),
+				"storageClassName": o.Spec.StorageClassName,
+				"claim":            claim,
+			},
+			Revision: 1,
+		},
+	}
+
+	// PersistentVolume -> StorageClass relationship
+	if o.Spec.StorageClassName != "" {
+		result.Relationships = append(result.Relationships, graph.GraphRelationship{
+			Source:           key,
+			Target:           graph.EntityKey{Name: o.Spec.StorageClassName, Type: "StorageClass"},
+			RelationshipType: "provisioned_by",
+		})
+	}
+
+	// PersistentVolume -> Node relationships for volumes pinned to specific
+	// nodes, such as local volumes
+	if o.Spec.NodeAffinity != nil && o.Spec.NodeAffinity.Required != nil {
+		for _, term := range o.Spec.NodeAffinity.Required.NodeSelectorTerms {
+			for _, expression := range term.MatchExpressions {
+				if expression.Key != corev1.LabelHostname || expression.Operator != corev1.NodeSelectorOpIn {
+					continue
+				}
+				for _, nodeName := range expression.Values {
+					result.Relationships = append(result.Relationships, graph.GraphRelationship{
+						Source:           key,
+						Target:           graph.EntityKey{Name: nodeName, Type: "Node"},
+						RelationshipType: "located_on",
+					})
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+func extractStorageClass(o *storagev1.StorageClass) *Result {
+	reclaimPolicy := ""
+	if o.ReclaimPolicy != nil {
+		reclaimPolicy = string(*o.ReclaimPolicy)
+	}
+	volumeBindingMode := ""
+	if o.VolumeBindingMode != nil {
+		volumeBindingMode = string(*o.VolumeBindingMode)
+	}
+	allowVolumeExpansion := false
+	if o.AllowVolumeExpansion != nil {
+		allowVolumeExpansion = *o.AllowVolumeExpansion
+	}
+
+	return &Result{
+		Node: graph.GraphNode{
+			Key: graph.EntityKey{Name: o.Name, Type: "StorageClass"},
+			Properties: map[string]interface{}{
+				"provisioner":          o.Provisioner,
+				"reclaimPolicy":        reclaimPolicy,
+				"volumeBindingMode":    volumeBindingMode,
+				"allowVolumeExpansion": allowVolumeExpansion,
+			},
+			Revision: 1,
+		},
+	}
+}
+
+// volumeRelationships returns a mounts relationship from a pod to each
+// PersistentVolumeClaim it uses, including claims created for generic
+// ephemeral volumes, which are named <pod>-<volume>
+func volumeRelationships(key graph.EntityKey, spec *corev1.PodSpec) []graph.GraphRelationship {
+	var rels []graph.GraphRelationship
+	for _, volume := range spec.Volumes {
+		claimName := ""
+		readOnly := false
+		switch {
+		case volume.PersistentVolumeClaim != nil:
+			claimName = volume.PersistentVolumeClaim.ClaimName
+			readOnly = volume.PersistentVolumeClaim.ReadOnly
+		case volume.Ephemeral != nil:
+			claimName = fmt.Sprintf("%s-%s", key.Name, volume.Name)
+		default:
+			continue
+		}
+
+		rels = append(rels, graph.GraphRelationship{
+			Source:           key,
+			Target:           graph.EntityKey{Name: claimName, Namespace: key.Namespace, Type: "PersistentVolumeClaim"},
+			RelationshipType: "mounts",
+			Properties: map[string]string{
+				"volume":   volume.Name,
+				"readOnly": fmt.Sprintf("%t", readOnly),
+			},
+		})
+	}
+	return rels
+}
+
+// quantityString formats a resource quantity, or returns the empty string if it is unset
+func quantityString(resources corev1.ResourceList, name corev1.ResourceName) string {
+	quantity, ok := resources[name]
+	if !ok {
+		return ""
+	}
+	return quantity.String()
+}
+
+// accessModesString joins access modes with commas
+func accessModesString(modes []corev1.PersistentVolumeAccessMode) string {
+	names := make([]string, len(modes))
+	for i, mode := range modes {
+		names[i] = string(mode)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,59 @@
+package extractor
+
+import (
+	"fmt"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// unstructuredExtractor extracts objects of a kind with no typed API, such
+// as a custom resource, from their unstructured form. Their properties all
+// come from the kind's property schema. The kind is the node type, as
+// returned by TypeName.
+type unstructuredExtractor struct {
+	kind string
+}
+
+// RegisterUnstructured registers an extractor for objects of the node type
+// kind that are only available in unstructured form, recording the given
+// properties. kind is named as by TypeName, so custom resources are
+// qualified by their group. It fails if kind already has an extractor.
+func RegisterUnstructured(kind string, properties map[string]PropertySpec) error {
+	if _, ok := registry[kind]; ok {
+		return fmt.Errorf("kind %s already has an extractor", kind)
+	}
+	register(unstructuredExtractor{kind: kind})
+	return AddPropertySchema(kind, properties)
+}
+
+func (e unstructuredExtractor) Kind() string {
+	return e.kind
+}
+
+func (e unstructuredExtractor) Extract(obj interface{}) (*Result, error) {
+	var o *unstructured.Unstructured
+	switch typed := obj.(type) {
+	case *unstructured.Unstructured:
+		o = typed
+	case map[string]interface{}:
+		o = &unstructured.Unstructured{Object: typed}
+	default:
+		return nil, fmt.Errorf("error converting %s: unsupported object type %T", e.kind, obj)
+	}
+
+	result := &Result{
+		Node: graph.GraphNode{
+			Key:        graph.EntityKey{Name: o.GetName(), Namespace: o.GetNamespace(), Type: e.kind},
+			Properties: map[string]interface{}{},
+			Revision:   1,
+		},
+	}
+	if err := addSchemaProperties(result, e.kind, o.Object); err != nil {
+		return nil, err
+	}
+	setIdentity(&result.Node, o, o.GetAPIVersion())
+	addCommonRelationships(result, o.GetOwnerReferences())
+
+	return result, nil
+}
@@ -1,11 +1,12 @@
 package graph
 
 import (
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"sort"
 	"sync"
-
-	appsv1 "k8s.io/api/apps/v1"
-	corev1 "k8s.io/api/core/v1"
+	"time"
 )
 
 // EntityKey uniquely identifies a Kubernetes resource.
@@ -15,209 +16,802 @@ type EntityKey struct {
 	Type      string `json:"type"`
 }
 
+// String formats the key as Type/namespace/name, or Type/name for
+// cluster-scoped resources
+func (k EntityKey) String() string {
+	if k.Namespace == "" {
+		return k.Type + "/" + k.Name
+	}
+	return k.Type + "/" + k.Namespace + "/" + k.Name
+}
+
 // GraphNode represents a node in the relationship graph.
+//
+// Property values are strings, except for those of a configured property
+// schema, which may also be numbers, bools, lists or maps.
+//
+// A key names an object, but an object deleted and recreated under the same
+// name is a new incarnation with a new UID. The lifecycle timestamps record
+// when the object was created, when the graph last saw the node change and,
+// for tombstones, when it was removed.
 type GraphNode struct {
-	Key        EntityKey         `json:"key"`
-	Properties map[string]string `json:"properties"`
-	Revision   int               `json:"revision"`
+	Key        EntityKey              `json:"key"`
+	UID        string                 `json:"uid,omitempty"`
+	APIVersion string                 `json:"apiVersion,omitempty"`
+	Properties map[string]interface{} `json:"properties"`
+	Revision   int                    `json:"revision"`
+	Created    *time.Time             `json:"created,omitempty"`
+	Updated    *time.Time             `json:"updated,omitempty"`
+	Deleted    *time.Time             `json:"deleted,omitempty"`
 }
 
 // GraphRelationship represents an edge/relationship in the graph.
+//
+// The Qualifier distinguishes parallel relationships of the same type
+// between the same two nodes, such as one Ingress routing several paths to
+// one Service. It is empty for most relationships.
 type GraphRelationship struct {
 	Source           EntityKey         `json:"source"`
 	Target           EntityKey         `json:"target"`
 	RelationshipType string            `json:"relationshipType"`
+	Qualifier        string            `json:"qualifier,omitempty"`
 	Properties       map[string]string `json:"properties"`
 	Revision         int               `json:"revision"`
 }
 
+// relationshipKey uniquely identifies a relationship in the graph.
+type relationshipKey struct {
+	source           EntityKey
+	target           EntityKey
+	relationshipType string
+	qualifier        string
+}
+
+func (r *GraphRelationship) key() relationshipKey {
+	return relationshipKey{source: r.Source, target: r.Target, relationshipType: r.RelationshipType, qualifier: r.Qualifier}
+}
+
+// DanglingPolicy controls how the graph treats relationships whose source
+// or target node has not been added yet.
+type DanglingPolicy int
+
+const (
+	// DanglingKeep adds relationships regardless of whether their nodes exist
+	DanglingKeep DanglingPolicy = iota
+	// DanglingDrop discards relationships that reference a missing node
+	DanglingDrop
+	// DanglingDefer holds relationships that reference a missing node and
+	// adds them once both of their nodes exist
+	DanglingDefer
+)
+
+// String returns the flag value for the policy
+func (p DanglingPolicy) String() string {
+	switch p {
+	case DanglingKeep:
+		return "keep"
+	case DanglingDrop:
+		return "drop"
+	case DanglingDefer:
+		return "defer"
+	default:
+		return fmt.Sprintf("DanglingPolicy(%d)", int(p))
+	}
+}
+
+// ParseDanglingPolicy parses a policy name as returned by DanglingPolicy.String
+func ParseDanglingPolicy(name string) (DanglingPolicy, error) {
+	for _, p := range []DanglingPolicy{DanglingKeep, DanglingDrop, DanglingDefer} {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+	return DanglingKeep, fmt.Errorf("unknown dangling edge policy %q", name)
+}
+
+// Option configures a Graph
+type Option func(*Graph)
+
+// WithDanglingPolicy sets the policy for relationships that reference missing nodes
+func WithDanglingPolicy(policy DanglingPolicy) Option {
+	return func(g *Graph) {
+		g.dangling = policy
+	}
+}
+
+// WithTombstoneRetention keeps removed nodes, and the incarnations of nodes
+// replaced by one with a new UID, as tombstones for the given duration
+func WithTombstoneRetention(retention time.Duration) Option {
+	return func(g *Graph) {
+		g.tombstoneRetention = retention
+	}
+}
+
 // Graph holds the complete set of nodes and relationships.
+//
+// Nodes and relationships are stored in maps keyed by their identity, and
+// every node has adjacency indexes of its outgoing and incoming
+// relationships, so lookups and updates take constant time regardless of
+// graph size.
 type Graph struct {
-	Nodes         []GraphNode         `json:"nodes"`
-	Relationships []GraphRelationship `json:"relationships"`
 	mu            sync.RWMutex
+	nodes         map[EntityKey]*GraphNode
+	relationships map[relationshipKey]*GraphRelationship
+	outgoing      map[EntityKey]map[relationshipKey]struct{}
+	incoming      map[EntityKey]map[relationshipKey]struct{}
 	revision      int
+	dangling      DanglingPolicy
+	now           func() time.Time
+
+	// Removed nodes, in the order they were removed
+	tombstones         []GraphNode
+	tombstoneRetention time.Duration
+
+	// Relationships held back under DanglingDefer, indexed by both endpoints
+	pending       map[relationshipKey]*GraphRelationship
+	pendingByNode map[EntityKey]map[relationshipKey]struct{}
+
+	// Relationships asserted by each origin and the origins asserting each
+	// relationship, as maintained by SyncRelationships
+	asserted map[string]map[relationshipKey]struct{}
+	origins  map[relationshipKey]map[string]struct{}
+
+	// Nodes asserted by each origin and the origins asserting each node, as
+	// maintained by SyncNodes
+	assertedNodes map[string]map[EntityKey]struct{}
+	nodeOrigins   map[EntityKey]map[string]struct{}
+}
+
+// graphJSON is the serialized form of a Graph. Namespaces groups the keys of
+// namespaced nodes by namespace; it is derived from Nodes and ignored when
+// decoding.
+type graphJSON struct {
+	Nodes         []GraphNode            `json:"nodes"`
+	Relationships []GraphRelationship    `json:"relationships"`
+	Namespaces    map[string][]EntityKey `json:"namespaces,omitempty"`
+	Tombstones    []GraphNode            `json:"tombstones,omitempty"`
 }
 
 // NewGraph creates a new empty graph
-func NewGraph() *Graph {
-	return &Graph{
-		Nodes:         make([]GraphNode, 0),
-		Relationships: make([]GraphRelationship, 0),
+func NewGraph(opts ...Option) *Graph {
+	g := &Graph{
+		nodes:         make(map[EntityKey]*GraphNode),
+		relationships: make(map[relationshipKey]*GraphRelationship),
+		outgoing:      make(map[EntityKey]map[relationshipKey]struct{}),
+		incoming:      make(map[EntityKey]map[relationshipKey]struct{}),
 		revision:      1,
+		dangling:      DanglingKeep,
+		now:           time.Now,
+		pending:       make(map[relationshipKey]*GraphRelationship),
+		pendingByNode: make(map[EntityKey]map[relationshipKey]struct{}),
+		asserted:      make(map[string]map[relationshipKey]struct{}),
+		origins:       make(map[relationshipKey]map[string]struct{}),
+		assertedNodes: make(map[string]map[EntityKey]struct{}),
+		nodeOrigins:   make(map[EntityKey]map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(g)
 	}
+	return g
 }
 
-// AddNode adds a node to the graph
-func (g *Graph) AddNode(obj interface{}) {
+// AddNode adds a node to the graph, replacing any existing node with the same key
+func (g *Graph) AddNode(node GraphNode) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	node := objectToGraphNode(obj)
-	if node == nil {
-		return
-	}
+	g.addNode(node)
+}
 
-	// Check if node already exists
-	for i, n := range g.Nodes {
-		if n.Key.Name == node.Key.Name && n.Key.Namespace == node.Key.Namespace && n.Key.Type == node.Key.Type {
-			g.Nodes[i] = *node
-			g.revision++
-			return
+// addNode adds or replaces a node and promotes its deferred relationships.
+// A node with a different UID than the one it replaces is a new incarnation,
+// and the old one becomes a tombstone.
+// The caller must hold the write lock.
+func (g *Graph) addNode(node GraphNode) {
+	now := g.now()
+	updated := now
+	node.Deleted = nil
+	if existing, ok := g.nodes[node.Key]; ok {
+		if existing.UID != node.UID && existing.UID != "" && node.UID != "" {
+			g.tombstone(*existing, now)
+		} else {
+			if node.Created == nil {
+				node.Created = existing.Created
+			}
+			if existing.Updated != nil && existing.APIVersion == node.APIVersion && reflect.DeepEqual(existing.Properties, node.Properties) {
+				updated = *existing.Updated
+			}
 		}
 	}
+	if node.Created == nil {
+		// Nodes without an object are created when first seen
+		node.Created = &now
+	}
+	node.Updated = &updated
 
-	// Add new node
-	g.Nodes = append(g.Nodes, *node)
+	g.nodes[node.Key] = &node
 	g.revision++
+
+	// Promote deferred relationships whose nodes now both exist
+	for rk := range g.pendingByNode[node.Key] {
+		if g.hasNode(rk.source) && g.hasNode(rk.target) {
+			rel := g.pending[rk]
+			g.unpend(rk)
+			g.insertRelationship(rk, rel)
+		}
+	}
 }
 
 // UpdateNode updates an existing node in the graph
-func (g *Graph) UpdateNode(obj interface{}) {
-	g.AddNode(obj) // AddNode handles both adding and updating
+func (g *Graph) UpdateNode(node GraphNode) {
+	g.AddNode(node) // AddNode handles both adding and updating
 }
 
-// RemoveNode removes a node from the graph
-func (g *Graph) RemoveNode(obj interface{}) {
+// RemoveNode removes a node from the graph along with the relationships
+// involving it. Relationships that an origin still asserts through
+// SyncRelationships, such as those of other objects referencing the node,
+// stay tracked by their origins: under DanglingKeep and DanglingDefer they
+// are held back and restored if the node is added again, and under
+// DanglingDrop they are discarded.
+func (g *Graph) RemoveNode(key EntityKey) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	node := objectToGraphNode(obj)
-	if node == nil {
-		return
+	g.removeNode(key)
+}
+
+// removeNode removes a node and detaches its relationships.
+// The caller must hold the write lock.
+func (g *Graph) removeNode(key EntityKey) {
+	if node, ok := g.nodes[key]; ok {
+		g.tombstone(*node, g.now())
+		delete(g.nodes, key)
+		g.revision++
 	}
 
-	// Remove node
-	for i, n := range g.Nodes {
-		if n.Key.Name == node.Key.Name && n.Key.Namespace == node.Key.Namespace && n.Key.Type == node.Key.Type {
-			g.Nodes = append(g.Nodes[:i], g.Nodes[i+1:]...)
-			g.revision++
-			return
+	for rk := range g.outgoing[key] {
+		g.detach(rk)
+	}
+	for rk := range g.incoming[key] {
+		g.detach(rk)
+	}
+	for rk := range g.pendingByNode[key] {
+		if len(g.origins[rk]) == 0 {
+			g.unpend(rk)
 		}
 	}
+}
 
-	// Remove relationships involving this node
-	for i := 0; i < len(g.Relationships); i++ {
-		rel := g.Relationships[i]
-		if (rel.Source.Name == node.Key.Name && rel.Source.Namespace == node.Key.Namespace && rel.Source.Type == node.Key.Type) ||
-			(rel.Target.Name == node.Key.Name && rel.Target.Namespace == node.Key.Namespace && rel.Target.Type == node.Key.Type) {
-			g.Relationships = append(g.Relationships[:i], g.Relationships[i+1:]...)
-			i--
-		}
+// Node returns a copy of the node with the given key
+func (g *Graph) Node(key EntityKey) (GraphNode, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	node, ok := g.nodes[key]
+	if !ok {
+		return GraphNode{}, false
 	}
+	return copyNode(node), true
+}
+
+// Nodes returns a snapshot of all nodes, sorted by key
+func (g *Graph) Nodes() []GraphNode {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.sortedNodes()
+}
+
+// Relationships returns a snapshot of all relationships, sorted by key
+func (g *Graph) Relationships() []GraphRelationship {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.sortedRelationships()
+}
+
+// Outgoing returns the relationships whose source is the given node
+func (g *Graph) Outgoing(key EntityKey) []GraphRelationship {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.collect(g.outgoing[key])
 }
 
-// objectToGraphNode converts a Kubernetes object to a GraphNode
-func objectToGraphNode(obj interface{}) *GraphNode {
-	var key EntityKey
-	var properties map[string]string
+// Incoming returns the relationships whose target is the given node
+func (g *Graph) Incoming(key EntityKey) []GraphRelationship {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
 
-	switch o := obj.(type) {
-	case *corev1.Pod:
-		key = EntityKey{
-			Name:      o.Name,
-			Namespace: o.Namespace,
-			Type:      "Pod",
+	return g.collect(g.incoming[key])
+}
+
+// Tombstones returns the nodes removed within the tombstone retention, in
+// the order they were removed
+func (g *Graph) Tombstones() []GraphNode {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.liveTombstones()
+}
+
+// Revision returns the graph revision, which increases on every change
+func (g *Graph) Revision() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	return g.revision
+}
+
+// AddRelationship adds a relationship to the graph
+func (g *Graph) AddRelationship(source, target EntityKey, relationshipType string, properties map[string]string) {
+	g.PutRelationship(GraphRelationship{
+		Source:           source,
+		Target:           target,
+		RelationshipType: relationshipType,
+		Properties:       properties,
+	})
+}
+
+// PutRelationship adds a relationship to the graph, or updates the
+// properties of the existing relationship with the same source, target,
+// type and qualifier
+func (g *Graph) PutRelationship(rel GraphRelationship) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.putRelationship(rel)
+}
+
+// RemoveRelationship removes a relationship from the graph
+func (g *Graph) RemoveRelationship(source, target EntityKey, relationshipType string) {
+	g.RemoveQualifiedRelationship(source, target, relationshipType, "")
+}
+
+// RemoveQualifiedRelationship removes one of several parallel relationships
+// of the same type, identified by its qualifier
+func (g *Graph) RemoveQualifiedRelationship(source, target EntityKey, relationshipType, qualifier string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	rk := relationshipKey{source: source, target: target, relationshipType: relationshipType, qualifier: qualifier}
+	g.removeRelationship(rk)
+	g.unpend(rk)
+	g.forget(rk)
+}
+
+// SyncRelationships replaces the set of relationships asserted by origin,
+// typically the object the relationships were derived from. Relationships in
+// rels are added or updated, and relationships the origin asserted
+// previously but no longer does are removed unless another origin still
+// asserts them. Syncing an empty set releases the origin.
+func (g *Graph) SyncRelationships(origin string, rels []GraphRelationship) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	next := make(map[relationshipKey]struct{}, len(rels))
+	for _, rel := range rels {
+		rk := rel.key()
+		next[rk] = struct{}{}
+		g.putRelationship(rel)
+
+		origins, ok := g.origins[rk]
+		if !ok {
+			origins = make(map[string]struct{})
+			g.origins[rk] = origins
 		}
-		properties = map[string]string{
-			"status": string(o.Status.Phase),
+		origins[origin] = struct{}{}
+	}
+
+	// Remove relationships that no origin asserts any more
+	for rk := range g.asserted[origin] {
+		if _, ok := next[rk]; ok {
+			continue
 		}
-	case *appsv1.ReplicaSet:
-		key = EntityKey{
-			Name:      o.Name,
-			Namespace: o.Namespace,
-			Type:      "ReplicaSet",
+		origins := g.origins[rk]
+		delete(origins, origin)
+		if len(origins) == 0 {
+			delete(g.origins, rk)
+			g.removeRelationship(rk)
+			g.unpend(rk)
 		}
-		properties = map[string]string{
-			"replicas": fmt.Sprintf("%d", *o.Spec.Replicas),
+	}
+
+	if len(next) == 0 {
+		delete(g.asserted, origin)
+	} else {
+		g.asserted[origin] = next
+	}
+}
+
+// SyncNodes replaces the set of nodes asserted by origin. It is meant for
+// nodes that have no Kubernetes object of their own, such as the users a
+// RoleBinding names: nodes in nodes are added or updated, and nodes the
+// origin asserted previously but no longer does are removed once no origin
+// asserts them. Syncing an empty set releases the origin.
+func (g *Graph) SyncNodes(origin string, nodes []GraphNode) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	next := make(map[EntityKey]struct{}, len(nodes))
+	for _, node := range nodes {
+		next[node.Key] = struct{}{}
+		g.addNode(node)
+
+		origins, ok := g.nodeOrigins[node.Key]
+		if !ok {
+			origins = make(map[string]struct{})
+			g.nodeOrigins[node.Key] = origins
 		}
-	case *appsv1.Deployment:
-		key = EntityKey{
-			Name:      o.Name,
-			Namespace: o.Namespace,
-			Type:      "Deployment",
+		origins[origin] = struct{}{}
+	}
+
+	// Remove nodes that no origin asserts any more
+	for key := range g.assertedNodes[origin] {
+		if _, ok := next[key]; ok {
+			continue
 		}
-		properties = map[string]string{
-			"replicas": fmt.Sprintf("%d", *o.Spec.Replicas),
+		origins := g.nodeOrigins[key]
+		delete(origins, origin)
+		if len(origins) == 0 {
+			delete(g.nodeOrigins, key)
+			g.removeNode(key)
 		}
-	case *corev1.Node:
-		key = EntityKey{
-			Name:      o.Name,
-			Namespace: "",
-			Type:      "Node",
+	}
+
+	if len(next) == 0 {
+		delete(g.assertedNodes, origin)
+	} else {
+		g.assertedNodes[origin] = next
+	}
+}
+
+// MarshalJSON serializes the graph as sorted lists of nodes and relationships
+func (g *Graph) MarshalJSON() ([]byte, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	nodes := g.sortedNodes()
+	return json.Marshal(graphJSON{
+		Nodes:         nodes,
+		Relationships: g.sortedRelationships(),
+		Namespaces:    groupByNamespace(nodes),
+		Tombstones:    g.liveTombstones(),
+	})
+}
+
+// UnmarshalJSON replaces the contents of the graph with serialized nodes and relationships
+func (g *Graph) UnmarshalJSON(data []byte) error {
+	var decoded graphJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.nodes = make(map[EntityKey]*GraphNode, len(decoded.Nodes))
+	g.relationships = make(map[relationshipKey]*GraphRelationship, len(decoded.Relationships))
+	g.outgoing = make(map[EntityKey]map[relationshipKey]struct{})
+	g.incoming = make(map[EntityKey]map[relationshipKey]struct{})
+	g.pending = make(map[relationshipKey]*GraphRelationship)
+	g.pendingByNode = make(map[EntityKey]map[relationshipKey]struct{})
+	g.asserted = make(map[string]map[relationshipKey]struct{})
+	g.origins = make(map[relationshipKey]map[string]struct{})
+	g.assertedNodes = make(map[string]map[EntityKey]struct{})
+	g.nodeOrigins = make(map[EntityKey]map[string]struct{})
+	g.tombstones = decoded.Tombstones
+
+	for i := range decoded.Nodes {
+		node := decoded.Nodes[i]
+		g.nodes[node.Key] = &node
+	}
+	for i := range decoded.Relationships {
+		rel := decoded.Relationships[i]
+		rk := rel.key()
+		g.relationships[rk] = &rel
+		index(g.outgoing, rel.Source, rk)
+		index(g.incoming, rel.Target, rk)
+	}
+	g.revision++
+	return nil
+}
+
+// putRelationship adds or updates a relationship.
+// The caller must hold the write lock.
+func (g *Graph) putRelationship(rel GraphRelationship) {
+	rk := rel.key()
+
+	// Check if relationship already exists
+	if existing, ok := g.relationships[rk]; ok {
+		existing.Properties = rel.Properties
+		existing.Revision++
+		g.revision++
+		return
+	}
+
+	// A relationship deferred when one of its nodes was removed stays
+	// deferred until the node is added again, whatever the policy
+	if deferred, ok := g.pending[rk]; ok {
+		deferred.Properties = rel.Properties
+		return
+	}
+
+	rel.Revision = 1
+
+	// Apply the dangling edge policy if either node is missing
+	if g.dangling != DanglingKeep && (!g.hasNode(rel.Source) || !g.hasNode(rel.Target)) {
+		if g.dangling == DanglingDefer {
+			g.pend(rk, &rel)
 		}
-		properties = map[string]string{
-			"status": string(o.Status.Phase),
+		return
+	}
+
+	// Add new relationship
+	g.insertRelationship(rk, &rel)
+}
+
+// hasNode reports whether the graph contains a node.
+// The caller must hold the read lock.
+func (g *Graph) hasNode(key EntityKey) bool {
+	_, ok := g.nodes[key]
+	return ok
+}
+
+// insertRelationship adds a new relationship and its index entries.
+// The caller must hold the write lock.
+func (g *Graph) insertRelationship(rk relationshipKey, rel *GraphRelationship) {
+	g.relationships[rk] = rel
+	index(g.outgoing, rk.source, rk)
+	index(g.incoming, rk.target, rk)
+	g.revision++
+}
+
+// detach handles a relationship of a node being removed. A relationship no
+// origin asserts is removed. One that is still asserted stays tracked by its
+// origins and leaves the graph either way: it is deferred until the node is
+// added again, or dropped under DanglingDrop. DanglingKeep only keeps
+// relationships to nodes that have not been seen yet.
+// The caller must hold the write lock.
+func (g *Graph) detach(rk relationshipKey) {
+	rel, ok := g.relationships[rk]
+	if !ok {
+		return
+	}
+	g.removeRelationship(rk)
+	if len(g.origins[rk]) > 0 && g.dangling != DanglingDrop {
+		g.pend(rk, rel)
+	}
+}
+
+// pend holds back a relationship until both of its nodes exist.
+// The caller must hold the write lock.
+func (g *Graph) pend(rk relationshipKey, rel *GraphRelationship) {
+	g.pending[rk] = rel
+	index(g.pendingByNode, rk.source, rk)
+	index(g.pendingByNode, rk.target, rk)
+}
+
+// unpend discards a deferred relationship.
+// The caller must hold the write lock.
+func (g *Graph) unpend(rk relationshipKey) {
+	if _, ok := g.pending[rk]; !ok {
+		return
+	}
+
+	delete(g.pending, rk)
+	unindex(g.pendingByNode, rk.source, rk)
+	unindex(g.pendingByNode, rk.target, rk)
+}
+
+// removeRelationship removes a relationship and its index entries.
+// The caller must hold the write lock.
+func (g *Graph) removeRelationship(rk relationshipKey) {
+	if _, ok := g.relationships[rk]; !ok {
+		return
+	}
+
+	delete(g.relationships, rk)
+	unindex(g.outgoing, rk.source, rk)
+	unindex(g.incoming, rk.target, rk)
+	g.revision++
+}
+
+// forget drops a removed relationship from the sets of every origin that
+// asserted it.
+// The caller must hold the write lock.
+func (g *Graph) forget(rk relationshipKey) {
+	for origin := range g.origins[rk] {
+		asserted := g.asserted[origin]
+		delete(asserted, rk)
+		if len(asserted) == 0 {
+			delete(g.asserted, origin)
 		}
-	case *corev1.Service:
-		key = EntityKey{
-			Name:      o.Name,
-			Namespace: o.Namespace,
-			Type:      "Service",
+	}
+	delete(g.origins, rk)
+}
+
+// tombstone records a removed node, and drops the tombstones that have
+// outlived the retention.
+// The caller must hold the write lock.
+func (g *Graph) tombstone(node GraphNode, now time.Time) {
+	if g.tombstoneRetention <= 0 {
+		return
+	}
+	node.Deleted = &now
+	g.tombstones = append(g.tombstones, node)
+
+	expired := 0
+	for expired < len(g.tombstones) && now.Sub(*g.tombstones[expired].Deleted) > g.tombstoneRetention {
+		expired++
+	}
+	g.tombstones = g.tombstones[expired:]
+}
+
+// liveTombstones returns copies of the tombstones within the retention.
+// The caller must hold the read lock.
+func (g *Graph) liveTombstones() []GraphNode {
+	now := g.now()
+	var tombstones []GraphNode
+	for i := range g.tombstones {
+		node := &g.tombstones[i]
+		if node.Deleted != nil && now.Sub(*node.Deleted) <= g.tombstoneRetention {
+			tombstones = append(tombstones, copyNode(node))
 		}
-		properties = map[string]string{
-			"type": string(o.Spec.Type),
+	}
+	return tombstones
+}
+
+// collect returns copies of the indexed relationships, sorted by key.
+// The caller must hold the read lock.
+func (g *Graph) collect(keys map[relationshipKey]struct{}) []GraphRelationship {
+	rels := make([]GraphRelationship, 0, len(keys))
+	for rk := range keys {
+		rels = append(rels, copyRelationship(g.relationships[rk]))
+	}
+	sortRelationships(rels)
+	return rels
+}
+
+// sortedNodes returns copies of all nodes, sorted by key.
+// The caller must hold the read lock.
+func (g *Graph) sortedNodes() []GraphNode {
+	nodes := make([]GraphNode, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		nodes = append(nodes, copyNode(node))
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return lessKey(nodes[i].Key, nodes[j].Key)
+	})
+	return nodes
+}
+
+// sortedRelationships returns copies of all relationships, sorted by key.
+// The caller must hold the read lock.
+func (g *Graph) sortedRelationships() []GraphRelationship {
+	rels := make([]GraphRelationship, 0, len(g.relationships))
+	for _, rel := range g.relationships {
+		rels = append(rels, copyRelationship(rel))
+	}
+	sortRelationships(rels)
+	return rels
+}
+
+// copyNode returns a copy of node that shares no properties or timestamps
+// with it, so callers may change it without holding the lock
+func copyNode(node *GraphNode) GraphNode {
+	copied := *node
+	if node.Properties != nil {
+		copied.Properties = copyValue(node.Properties).(map[string]interface{})
+	}
+	copied.Created = copyTime(node.Created)
+	copied.Updated = copyTime(node.Updated)
+	copied.Deleted = copyTime(node.Deleted)
+	return copied
+}
+
+// copyRelationship returns a copy of rel that shares no properties with it
+func copyRelationship(rel *GraphRelationship) GraphRelationship {
+	copied := *rel
+	if rel.Properties != nil {
+		copied.Properties = make(map[string]string, len(rel.Properties))
+		for k, v := range rel.Properties {
+			copied.Properties[k] = v
 		}
-	case *corev1.ConfigMap:
-		key = EntityKey{
-			Name:      o.Name,
-			Namespace: o.Namespace,
-			Type:      "ConfigMap",
+	}
+	return copied
+}
+
+// copyValue deep-copies a property value, including the lists and maps of
+// schema properties
+func copyValue(v interface{}) interface{} {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(typed))
+		for k, elem := range typed {
+			copied[k] = copyValue(elem)
 		}
-		properties = map[string]string{
-			"data": fmt.Sprintf("%v", o.Data),
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(typed))
+		for i, elem := range typed {
+			copied[i] = copyValue(elem)
 		}
+		return copied
 	default:
-		return nil
+		return v
 	}
+}
 
-	return &GraphNode{
-		Key:        key,
-		Properties: properties,
-		Revision:   1,
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
 	}
+	copied := *t
+	return &copied
 }
 
-// AddRelationship adds a relationship to the graph
-func (g *Graph) AddRelationship(source, target EntityKey, relationshipType string, properties map[string]string) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
-
-	// Check if relationship already exists
-	for i, rel := range g.Relationships {
-		if rel.Source.Name == source.Name && rel.Source.Namespace == source.Namespace && rel.Source.Type == source.Type &&
-			rel.Target.Name == target.Name && rel.Target.Namespace == target.Namespace && rel.Target.Type == target.Type &&
-			rel.RelationshipType == relationshipType {
-			g.Relationships[i].Properties = properties
-			g.Relationships[i].Revision++
-			g.revision++
-			return
+// groupByNamespace returns the keys of the namespaced nodes in a sorted
+// slice of nodes, grouped by namespace
+func groupByNamespace(nodes []GraphNode) map[string][]EntityKey {
+	groups := make(map[string][]EntityKey)
+	for _, node := range nodes {
+		if node.Key.Namespace != "" {
+			groups[node.Key.Namespace] = append(groups[node.Key.Namespace], node.Key)
 		}
 	}
+	return groups
+}
 
-	// Add new relationship
-	g.Relationships = append(g.Relationships, GraphRelationship{
-		Source:           source,
-		Target:           target,
-		RelationshipType: relationshipType,
-		Properties:       properties,
-		Revision:         1,
+func sortRelationships(rels []GraphRelationship) {
+	sort.Slice(rels, func(i, j int) bool {
+		return lessRelationshipKey(rels[i].key(), rels[j].key())
 	})
-	g.revision++
 }
 
-// RemoveRelationship removes a relationship from the graph
-func (g *Graph) RemoveRelationship(source, target EntityKey, relationshipType string) {
-	g.mu.Lock()
-	defer g.mu.Unlock()
+// lessRelationshipKey orders relationship keys by source, target, type and qualifier
+func lessRelationshipKey(a, b relationshipKey) bool {
+	if a.source != b.source {
+		return lessKey(a.source, b.source)
+	}
+	if a.target != b.target {
+		return lessKey(a.target, b.target)
+	}
+	if a.relationshipType != b.relationshipType {
+		return a.relationshipType < b.relationshipType
+	}
+	return a.qualifier < b.qualifier
+}
 
-	for i, rel := range g.Relationships {
-		if rel.Source.Name == source.Name && rel.Source.Namespace == source.Namespace && rel.Source.Type == source.Type &&
-			rel.Target.Name == target.Name && rel.Target.Namespace == target.Namespace && rel.Target.Type == target.Type &&
-			rel.RelationshipType == relationshipType {
-			g.Relationships = append(g.Relationships[:i], g.Relationships[i+1:]...)
-			g.revision++
-			return
-		}
+// lessKey orders entity keys by type, namespace and name
+func lessKey(a, b EntityKey) bool {
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	if a.Namespace != b.Namespace {
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
+
+// index records rk in the adjacency set of key
+func index(adjacency map[EntityKey]map[relationshipKey]struct{}, key EntityKey, rk relationshipKey) {
+	set, ok := adjacency[key]
+	if !ok {
+		set = make(map[relationshipKey]struct{})
+		adjacency[key] = set
+	}
+	set[rk] = struct{}{}
+}
+
+// unindex removes rk from the adjacency set of key, dropping empty sets
+func unindex(adjacency map[EntityKey]map[relationshipKey]struct{}, key EntityKey, rk relationshipKey) {
+	set, ok := adjacency[key]
+	if !ok {
+		return
+	}
+	delete(set, rk)
+	if len(set) == 0 {
+		delete(adjacency, key)
 	}
 }
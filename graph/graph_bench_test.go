@@ -0,0 +1,73 @@
+package graph
+
+import (
+	"fmt"
+	"testing"
+)
+
+var benchmarkSizes = []int{1000, 10000, 100000}
+
+// buildGraph creates a graph of n pods spread over 100 nodes, each pod with
+// a runs_on relationship to its node
+func buildGraph(n int) *Graph {
+	g := NewGraph()
+	for i := 0; i < n; i++ {
+		pod := EntityKey{Name: fmt.Sprintf("pod-%d", i), Namespace: "default", Type: "Pod"}
+		node := EntityKey{Name: fmt.Sprintf("node-%d", i%100), Type: "Node"}
+		g.AddNode(GraphNode{Key: pod, Revision: 1})
+		g.AddNode(GraphNode{Key: node, Revision: 1})
+		g.AddRelationship(pod, node, "runs_on", nil)
+	}
+	return g
+}
+
+func BenchmarkBuildGraph(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("nodes=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buildGraph(size)
+			}
+		})
+	}
+}
+
+func BenchmarkAddNode(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("nodes=%d", size), func(b *testing.B) {
+			g := buildGraph(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := EntityKey{Name: fmt.Sprintf("pod-%d", i%size), Namespace: "default", Type: "Pod"}
+				g.AddNode(GraphNode{Key: key, Revision: 1})
+			}
+		})
+	}
+}
+
+func BenchmarkAddRemoveRelationship(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("nodes=%d", size), func(b *testing.B) {
+			g := buildGraph(size)
+			service := EntityKey{Name: "web", Namespace: "default", Type: "Service"}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pod := EntityKey{Name: fmt.Sprintf("pod-%d", i%size), Namespace: "default", Type: "Pod"}
+				g.AddRelationship(service, pod, "targets", nil)
+				g.RemoveRelationship(service, pod, "targets")
+			}
+		})
+	}
+}
+
+func BenchmarkOutgoing(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("nodes=%d", size), func(b *testing.B) {
+			g := buildGraph(size)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				g.Outgoing(EntityKey{Name: fmt.Sprintf("pod-%d", i%size), Namespace: "default", Type: "Pod"})
+			}
+		})
+	}
+}
@@ -0,0 +1,620 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+var (
+	deployment = EntityKey{Name: "web", Namespace: "default", Type: "Deployment"}
+	replicaSet = EntityKey{Name: "web-5d4f", Namespace: "default", Type: "ReplicaSet"}
+	podA       = EntityKey{Name: "web-5d4f-a", Namespace: "default", Type: "Pod"}
+	podB       = EntityKey{Name: "web-5d4f-b", Namespace: "default", Type: "Pod"}
+	service    = EntityKey{Name: "web", Namespace: "default", Type: "Service"}
+	ingress    = EntityKey{Name: "web", Namespace: "default", Type: "Ingress"}
+	node1      = EntityKey{Name: "node-1", Type: "Node"}
+	configMap  = EntityKey{Name: "settings", Namespace: "other", Type: "ConfigMap"}
+)
+
+// buildQueryGraph creates a Deployment with one ReplicaSet and two pods on a
+// node, exposed through a Service and an Ingress, plus an unrelated ConfigMap
+func buildQueryGraph() *Graph {
+	g := NewGraph()
+	for _, key := range []EntityKey{deployment, replicaSet, podA, podB, service, ingress, node1, configMap} {
+		g.AddNode(GraphNode{Key: key, Properties: map[string]interface{}{"name": key.Name}, Revision: 1})
+	}
+	g.AddRelationship(replicaSet, deployment, "owned_by", map[string]string{"controller": "true"})
+	g.AddRelationship(podA, replicaSet, "owned_by", nil)
+	g.AddRelationship(podB, replicaSet, "owned_by", nil)
+	g.AddRelationship(podA, node1, "runs_on", nil)
+	g.AddRelationship(podB, node1, "runs_on", nil)
+	g.AddRelationship(service, podA, "targets", nil)
+	g.AddRelationship(service, podB, "targets", nil)
+	g.AddRelationship(ingress, service, "routes_to", nil)
+	return g
+}
+
+func TestNeighbors(t *testing.T) {
+	g := buildQueryGraph()
+
+	tests := []struct {
+		name      string
+		key       EntityKey
+		direction Direction
+		relTypes  []string
+		want      []EntityKey
+	}{
+		{"outgoing", podA, DirectionOutgoing, nil, []EntityKey{node1, replicaSet}},
+		{"incoming", podA, DirectionIncoming, nil, []EntityKey{service}},
+		{"both", podA, DirectionBoth, nil, []EntityKey{node1, replicaSet, service}},
+		{"filtered by type", podA, DirectionOutgoing, []string{"runs_on"}, []EntityKey{node1}},
+		{"several types", podA, DirectionBoth, []string{"runs_on", "targets"}, []EntityKey{node1, service}},
+		{"distinct neighbors", node1, DirectionIncoming, nil, []EntityKey{podA, podB}},
+		{"no relationships", configMap, DirectionBoth, nil, nil},
+		{"missing node", EntityKey{Name: "missing", Type: "Pod"}, DirectionBoth, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := g.Neighbors(tt.key, tt.direction, tt.relTypes...)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Neighbors(%v) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	g := buildQueryGraph()
+
+	path, ok := g.ShortestPath(ingress, node1)
+	if !ok {
+		t.Fatalf("ShortestPath(ingress, node) found no path")
+	}
+	var types []string
+	for _, rel := range path {
+		types = append(types, rel.RelationshipType)
+	}
+	if want := []string{"routes_to", "targets", "runs_on"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("ShortestPath(ingress, node) relationship types = %v, want %v", types, want)
+	}
+	if path[0].Source != ingress || path[len(path)-1].Target != node1 {
+		t.Errorf("ShortestPath(ingress, node) = %v, want a path from the ingress to the node", path)
+	}
+
+	// Paths follow relationships against their direction too
+	path, ok = g.ShortestPath(node1, deployment)
+	if !ok || len(path) != 3 {
+		t.Errorf("ShortestPath(node, deployment) = %v, %t, want 3 relationships", path, ok)
+	}
+
+	if path, ok := g.ShortestPath(podA, podA); !ok || len(path) != 0 {
+		t.Errorf("ShortestPath(pod, pod) = %v, %t, want an empty path", path, ok)
+	}
+	if path, ok := g.ShortestPath(podA, configMap); ok {
+		t.Errorf("ShortestPath(pod, configMap) = %v, want no path", path)
+	}
+}
+
+func TestDescendantsAndAncestors(t *testing.T) {
+	g := buildQueryGraph()
+
+	if got, want := g.Descendants(deployment), []EntityKey{podA, podB, replicaSet}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Descendants(deployment) = %v, want %v", got, want)
+	}
+	if got, want := g.Ancestors(podA), []EntityKey{deployment, replicaSet}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Ancestors(pod) = %v, want %v", got, want)
+	}
+	if got := g.Ancestors(deployment); got != nil {
+		t.Errorf("Ancestors(deployment) = %v, want none", got)
+	}
+
+	// Ownership cycles do not loop forever, and never include the node itself
+	g.AddRelationship(deployment, podA, "owned_by", nil)
+	if got, want := g.Ancestors(podA), []EntityKey{deployment, replicaSet}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Ancestors(pod) with a cycle = %v, want %v", got, want)
+	}
+}
+
+func TestSubgraph(t *testing.T) {
+	g := buildQueryGraph()
+
+	sub := g.Subgraph(func(node GraphNode) bool {
+		return node.Key.Namespace == "default" && node.Key.Type != "Service"
+	})
+
+	var keys []EntityKey
+	for _, node := range sub.Nodes() {
+		keys = append(keys, node.Key)
+	}
+	if want := []EntityKey{deployment, ingress, podA, podB, replicaSet}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("Subgraph nodes = %v, want %v", keys, want)
+	}
+
+	// Only relationships between matching nodes are kept
+	for _, rel := range sub.Relationships() {
+		if rel.RelationshipType != "owned_by" {
+			t.Errorf("Subgraph kept %s relationship from %v to %v", rel.RelationshipType, rel.Source, rel.Target)
+		}
+	}
+	if got := len(sub.Relationships()); got != 3 {
+		t.Errorf("Subgraph has %d relationships, want 3", got)
+	}
+	if got, want := sub.Descendants(deployment), []EntityKey{podA, podB, replicaSet}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Subgraph Descendants(deployment) = %v, want %v", got, want)
+	}
+
+	// The subgraph is independent of the graph it was taken from
+	sub.RemoveNode(podA)
+	sub.AddNode(GraphNode{Key: replicaSet, Properties: map[string]interface{}{"name": "changed"}})
+	if _, ok := g.Node(podA); !ok {
+		t.Errorf("removing a node from the subgraph removed it from the graph")
+	}
+	if original, _ := g.Node(replicaSet); original.Properties["name"] != replicaSet.Name {
+		t.Errorf("changing a subgraph node's properties changed the graph's")
+	}
+
+	// Nodes returned by the subgraph do not share its state either
+	node, _ := sub.Node(deployment)
+	node.Properties["name"] = "changed"
+	if node, _ := sub.Node(deployment); node.Properties["name"] != deployment.Name {
+		t.Errorf("changing a returned node's properties changed the subgraph's")
+	}
+	if original, _ := g.Node(deployment); original.Properties["name"] != deployment.Name {
+		t.Errorf("changing a returned subgraph node's properties changed the graph's")
+	}
+	if got := len(g.Outgoing(podA)); got != 2 {
+		t.Errorf("graph has %d relationships from the pod after changing the subgraph, want 2", got)
+	}
+}
+
+func TestResultsDoNotShareState(t *testing.T) {
+	g := buildQueryGraph()
+	g.AddNode(GraphNode{Key: configMap, Properties: map[string]interface{}{
+		"name": configMap.Name,
+		"keys": []interface{}{"a"},
+		"meta": map[string]interface{}{"team": "web"},
+	}})
+
+	// Changing returned nodes and relationships leaves the graph unchanged
+	node, _ := g.Node(configMap)
+	created := *node.Created
+	node.Properties["name"] = "changed"
+	node.Properties["keys"].([]interface{})[0] = "changed"
+	node.Properties["meta"].(map[string]interface{})["team"] = "changed"
+	*node.Created = created.Add(time.Hour)
+	for _, node := range g.Nodes() {
+		node.Properties["name"] = "changed"
+	}
+	for _, rel := range g.Outgoing(replicaSet) {
+		rel.Properties["controller"] = "false"
+	}
+	path, _ := g.ShortestPath(podA, deployment)
+	for _, rel := range path {
+		if rel.Properties != nil {
+			rel.Properties["controller"] = "false"
+		}
+	}
+	g.RemoveNode(podB)
+	for _, node := range g.Tombstones() {
+		node.Properties["name"] = "changed"
+	}
+	g.Subgraph(func(node GraphNode) bool {
+		node.Properties["name"] = "changed"
+		return true
+	})
+
+	original, _ := g.Node(configMap)
+	want := map[string]interface{}{
+		"name": configMap.Name,
+		"keys": []interface{}{"a"},
+		"meta": map[string]interface{}{"team": "web"},
+	}
+	if !reflect.DeepEqual(original.Properties, want) {
+		t.Errorf("node properties after changing results = %v, want %v", original.Properties, want)
+	}
+	if !original.Created.Equal(created) {
+		t.Errorf("node Created after changing a result = %v, want %v", original.Created, created)
+	}
+	for _, node := range g.Nodes() {
+		if node.Properties["name"] != node.Key.Name {
+			t.Errorf("%v name after changing results = %v, want %s", node.Key, node.Properties["name"], node.Key.Name)
+		}
+	}
+	if rels := g.Outgoing(replicaSet); rels[0].Properties["controller"] != "true" {
+		t.Errorf("relationship properties after changing results = %v, want controller true", rels[0].Properties)
+	}
+	for _, node := range g.Tombstones() {
+		if node.Properties["name"] != node.Key.Name {
+			t.Errorf("tombstone %v name after changing results = %v, want %s", node.Key, node.Properties["name"], node.Key.Name)
+		}
+	}
+}
+
+func TestQueriesConcurrentWithUpdates(t *testing.T) {
+	g := buildQueryGraph()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			g.RemoveRelationship(podB, node1, "runs_on")
+			g.AddRelationship(podB, node1, "runs_on", nil)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			g.Neighbors(node1, DirectionIncoming)
+			g.ShortestPath(ingress, node1)
+			g.Descendants(deployment)
+			g.Subgraph(func(GraphNode) bool { return true })
+		}
+	}()
+	wg.Wait()
+}
+
+func TestRemoveNode(t *testing.T) {
+	asserted := relationshipKey{source: podA, target: configMap, relationshipType: "uses"}
+	unasserted := relationshipKey{source: service, target: configMap, relationshipType: "references"}
+
+	tests := []struct {
+		name   string
+		policy DanglingPolicy
+		// Whether the asserted relationship is back once the node is recreated
+		wantRestored bool
+	}{
+		{"keep", DanglingKeep, true},
+		{"drop", DanglingDrop, false},
+		{"defer", DanglingDefer, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGraph(WithDanglingPolicy(tt.policy))
+			for _, key := range []EntityKey{podA, service, configMap} {
+				g.AddNode(GraphNode{Key: key, Revision: 1})
+			}
+			g.SyncRelationships("extractor:"+podA.String(), []GraphRelationship{
+				{Source: podA, Target: configMap, RelationshipType: "uses"},
+			})
+			g.AddRelationship(service, configMap, "references", nil)
+
+			g.RemoveNode(configMap)
+			if got := g.Relationships(); len(got) != 0 {
+				t.Errorf("relationships after removing the node = %v, want none", got)
+			}
+			if got := g.Incoming(configMap); len(got) != 0 {
+				t.Errorf("Incoming(configMap) after removing the node = %v, want none", got)
+			}
+			if _, ok := g.origins[asserted]; !ok {
+				t.Errorf("removing the node stopped tracking the origin of the asserted relationship")
+			}
+
+			// Syncing the origin again while the node is gone keeps the
+			// relationship out of the graph
+			g.SyncRelationships("extractor:"+podA.String(), []GraphRelationship{
+				{Source: podA, Target: configMap, RelationshipType: "uses", Properties: map[string]string{"volume": "config"}},
+			})
+			if got := g.Relationships(); len(got) != 0 {
+				t.Errorf("relationships after syncing the origin = %v, want none", got)
+			}
+
+			g.AddNode(GraphNode{Key: configMap, Revision: 1})
+			_, restored := g.relationships[asserted]
+			if restored != tt.wantRestored {
+				t.Errorf("asserted relationship restored on recreate = %t, want %t", restored, tt.wantRestored)
+			}
+			if restored && g.relationships[asserted].Properties["volume"] != "config" {
+				t.Errorf("restored relationship properties = %v, want those of the last sync", g.relationships[asserted].Properties)
+			}
+			if _, ok := g.relationships[unasserted]; ok {
+				t.Errorf("unasserted relationship restored on recreate")
+			}
+			if len(g.pending) != 0 {
+				t.Errorf("pending relationships after recreating the node = %v, want none", g.pending)
+			}
+		})
+	}
+}
+
+func TestRemoveNodeReleasedOrigin(t *testing.T) {
+	g := NewGraph(WithDanglingPolicy(DanglingDefer))
+	g.AddNode(GraphNode{Key: podA, Revision: 1})
+	g.AddNode(GraphNode{Key: configMap, Revision: 1})
+	g.SyncRelationships("extractor:"+podA.String(), []GraphRelationship{
+		{Source: podA, Target: configMap, RelationshipType: "uses"},
+	})
+
+	// Releasing the origin while the node is gone discards the deferred relationship
+	g.RemoveNode(configMap)
+	g.SyncRelationships("extractor:"+podA.String(), nil)
+	if len(g.pending) != 0 {
+		t.Errorf("pending relationships after releasing the origin = %v, want none", g.pending)
+	}
+	g.AddNode(GraphNode{Key: configMap, Revision: 1})
+	if got := g.Relationships(); len(got) != 0 {
+		t.Errorf("relationships after recreating the node = %v, want none", got)
+	}
+}
+
+func TestDanglingRelationships(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy DanglingPolicy
+		// Whether the relationship is in the graph before and after its target is added
+		wantBefore, wantAfter bool
+	}{
+		{"keep", DanglingKeep, true, true},
+		{"drop", DanglingDrop, false, false},
+		{"defer", DanglingDefer, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGraph(WithDanglingPolicy(tt.policy))
+			g.AddNode(GraphNode{Key: podA, Revision: 1})
+			g.AddRelationship(podA, node1, "runs_on", nil)
+
+			if got := len(g.Outgoing(podA)) == 1; got != tt.wantBefore {
+				t.Errorf("relationship to an unseen node in the graph = %t, want %t", got, tt.wantBefore)
+			}
+			g.AddNode(GraphNode{Key: node1, Revision: 1})
+			if got := len(g.Outgoing(podA)) == 1; got != tt.wantAfter {
+				t.Errorf("relationship in the graph once its node is added = %t, want %t", got, tt.wantAfter)
+			}
+			if len(g.pending) != 0 || len(g.pendingByNode) != 0 {
+				t.Errorf("pending relationships once both nodes exist = %v, want none", g.pending)
+			}
+		})
+	}
+}
+
+func TestSyncRelationships(t *testing.T) {
+	runsOn := relationshipKey{source: podA, target: node1, relationshipType: "runs_on"}
+	usesConfig := relationshipKey{source: podA, target: configMap, relationshipType: "uses"}
+	targets := relationshipKey{source: service, target: podA, relationshipType: "targets"}
+
+	g := NewGraph()
+	for _, key := range []EntityKey{podA, service, node1, configMap} {
+		g.AddNode(GraphNode{Key: key, Revision: 1})
+	}
+
+	// The diff against the previous sync removes dropped references
+	g.SyncRelationships("pod", []GraphRelationship{
+		{Source: podA, Target: node1, RelationshipType: "runs_on"},
+		{Source: podA, Target: configMap, RelationshipType: "uses"},
+	})
+	g.SyncRelationships("pod", []GraphRelationship{
+		{Source: podA, Target: node1, RelationshipType: "runs_on"},
+	})
+	if _, ok := g.relationships[usesConfig]; ok {
+		t.Errorf("relationship dropped from the origin's set is still in the graph")
+	}
+	if _, ok := g.origins[usesConfig]; ok {
+		t.Errorf("relationship dropped from the origin's set still has origins")
+	}
+	if _, ok := g.relationships[runsOn]; !ok {
+		t.Errorf("relationship still in the origin's set was removed")
+	}
+
+	// A relationship asserted by two origins survives until both release it
+	g.SyncRelationships("endpointslice-a", []GraphRelationship{{Source: service, Target: podA, RelationshipType: "targets"}})
+	g.SyncRelationships("endpointslice-b", []GraphRelationship{{Source: service, Target: podA, RelationshipType: "targets"}})
+	g.SyncRelationships("endpointslice-a", nil)
+	if _, ok := g.relationships[targets]; !ok {
+		t.Errorf("relationship released by one of two origins was removed")
+	}
+	if _, ok := g.asserted["endpointslice-a"]; ok {
+		t.Errorf("released origin is still tracked")
+	}
+	g.SyncRelationships("endpointslice-b", nil)
+	if _, ok := g.relationships[targets]; ok {
+		t.Errorf("relationship released by every origin is still in the graph")
+	}
+
+	// Removing a relationship directly forgets it in its origins' sets
+	g.SyncRelationships("scheduler", []GraphRelationship{{Source: podA, Target: node1, RelationshipType: "runs_on"}})
+	g.RemoveRelationship(podA, node1, "runs_on")
+	if _, ok := g.origins[runsOn]; ok {
+		t.Errorf("removed relationship still has origins")
+	}
+	for _, origin := range []string{"pod", "scheduler"} {
+		if _, ok := g.asserted[origin][runsOn]; ok {
+			t.Errorf("origin %s still asserts the removed relationship", origin)
+		}
+	}
+	if len(g.asserted) != 0 || len(g.origins) != 0 {
+		t.Errorf("origins left after every relationship was removed: %v, %v", g.asserted, g.origins)
+	}
+}
+
+func TestSyncNodes(t *testing.T) {
+	user := EntityKey{Name: "alice", Type: "User"}
+	group := EntityKey{Name: "admins", Type: "Group"}
+
+	g := NewGraph()
+	g.SyncNodes("binding-a", []GraphNode{{Key: user, Revision: 1}, {Key: group, Revision: 1}})
+	g.SyncNodes("binding-b", []GraphNode{{Key: user, Revision: 1}})
+
+	// Dropping a node from the only origin asserting it removes it
+	g.SyncNodes("binding-a", []GraphNode{{Key: user, Revision: 1}})
+	if _, ok := g.Node(group); ok {
+		t.Errorf("node dropped by its only origin is still in the graph")
+	}
+
+	// A node is removed only once its last origin releases it
+	g.SyncNodes("binding-a", nil)
+	if _, ok := g.Node(user); !ok {
+		t.Errorf("node released by one of two origins was removed")
+	}
+	g.SyncNodes("binding-b", nil)
+	if _, ok := g.Node(user); ok {
+		t.Errorf("node released by every origin is still in the graph")
+	}
+	if len(g.assertedNodes) != 0 || len(g.nodeOrigins) != 0 {
+		t.Errorf("origins left after every node was released: %v, %v", g.assertedNodes, g.nodeOrigins)
+	}
+}
+
+// fakeClock is a settable clock for the graph's timestamps
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestNodeLifecycle(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	g := NewGraph(WithTombstoneRetention(time.Hour))
+	g.now = clock.Now
+
+	created := clock.now.Add(-time.Minute)
+	g.AddNode(GraphNode{Key: podA, UID: "uid-1", Properties: map[string]interface{}{"phase": "Pending"}, Created: &created})
+
+	// Updates carrying the same properties keep the time of the last change,
+	// and updates without a creation time keep the existing one
+	clock.advance(time.Minute)
+	g.AddNode(GraphNode{Key: podA, UID: "uid-1", Properties: map[string]interface{}{"phase": "Pending"}})
+	node, _ := g.Node(podA)
+	if !node.Created.Equal(created) {
+		t.Errorf("Created after an update = %v, want %v", node.Created, created)
+	}
+	if want := created.Add(time.Minute); !node.Updated.Equal(want) {
+		t.Errorf("Updated after an unchanged update = %v, want %v", node.Updated, want)
+	}
+
+	clock.advance(time.Minute)
+	g.AddNode(GraphNode{Key: podA, UID: "uid-1", Properties: map[string]interface{}{"phase": "Running"}})
+	node, _ = g.Node(podA)
+	if !node.Updated.Equal(clock.now) {
+		t.Errorf("Updated after a change = %v, want %v", node.Updated, clock.now)
+	}
+	if tombstones := g.Tombstones(); len(tombstones) != 0 {
+		t.Errorf("Tombstones after updates = %v, want none", tombstones)
+	}
+
+	// A new UID is a new incarnation, and the old one becomes a tombstone
+	clock.advance(time.Minute)
+	g.AddNode(GraphNode{Key: podA, UID: "uid-2", Properties: map[string]interface{}{"phase": "Pending"}})
+	node, _ = g.Node(podA)
+	if node.UID != "uid-2" || !node.Created.Equal(clock.now) {
+		t.Errorf("recreated node = %s created %v, want uid-2 created %v", node.UID, node.Created, clock.now)
+	}
+	tombstones := g.Tombstones()
+	if len(tombstones) != 1 || tombstones[0].UID != "uid-1" || !tombstones[0].Deleted.Equal(clock.now) {
+		t.Fatalf("Tombstones after recreating the node = %v, want uid-1 deleted %v", tombstones, clock.now)
+	}
+	if !tombstones[0].Created.Equal(created) {
+		t.Errorf("tombstone Created = %v, want %v", tombstones[0].Created, created)
+	}
+	if node.Deleted != nil {
+		t.Errorf("live node has Deleted = %v", node.Deleted)
+	}
+
+	// Nodes without a creation time are created when first seen
+	g.AddNode(GraphNode{Key: node1})
+	if node, _ := g.Node(node1); !node.Created.Equal(clock.now) {
+		t.Errorf("Created of a node without one = %v, want %v", node.Created, clock.now)
+	}
+}
+
+func TestTombstoneRetention(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	g := NewGraph(WithTombstoneRetention(time.Hour))
+	g.now = clock.Now
+
+	g.AddNode(GraphNode{Key: podA, UID: "uid-a"})
+	g.AddNode(GraphNode{Key: podB, UID: "uid-b"})
+	g.RemoveNode(podA)
+
+	clock.advance(time.Hour)
+	if tombstones := g.Tombstones(); len(tombstones) != 1 || tombstones[0].Key != podA {
+		t.Errorf("Tombstones at the end of the retention = %v, want %v", tombstones, podA)
+	}
+
+	// Expired tombstones are hidden, and dropped when the next one is recorded
+	clock.advance(time.Second)
+	if tombstones := g.Tombstones(); len(tombstones) != 0 {
+		t.Errorf("Tombstones after the retention = %v, want none", tombstones)
+	}
+	g.RemoveNode(podB)
+	if len(g.tombstones) != 1 || g.tombstones[0].Key != podB {
+		t.Errorf("recorded tombstones = %v, want only %v", g.tombstones, podB)
+	}
+
+	// A retention of 0 disables tombstones
+	g = NewGraph(WithTombstoneRetention(0))
+	g.now = clock.Now
+	g.AddNode(GraphNode{Key: podA, UID: "uid-1"})
+	g.AddNode(GraphNode{Key: podA, UID: "uid-2"})
+	g.RemoveNode(podA)
+	if len(g.tombstones) != 0 || len(g.Tombstones()) != 0 {
+		t.Errorf("tombstones with a retention of 0 = %v, want none", g.tombstones)
+	}
+}
+
+// TestMarshalJSONGolden pins the serialized shape of a graph with namespaced
+// and cluster-scoped nodes, relationships with and without a qualifier, and a
+// tombstone. Run with -update to rewrite testdata/graph.golden.json.
+func TestMarshalJSONGolden(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	g := NewGraph(WithTombstoneRetention(time.Hour))
+	g.now = clock.Now
+
+	created := clock.now.Add(-time.Hour)
+	g.AddNode(GraphNode{
+		Key:        podA,
+		UID:        "uid-a",
+		APIVersion: "v1",
+		Properties: map[string]interface{}{"phase": "Running", "restarts": 2, "ready": true},
+		Revision:   1,
+		Created:    &created,
+	})
+	g.AddNode(GraphNode{Key: node1, UID: "uid-node", APIVersion: "v1", Properties: map[string]interface{}{}, Revision: 1})
+	g.AddNode(GraphNode{Key: service, UID: "uid-svc", APIVersion: "v1", Properties: map[string]interface{}{"type": "ClusterIP"}, Revision: 1})
+	g.AddNode(GraphNode{Key: configMap, UID: "uid-cm", APIVersion: "v1", Properties: map[string]interface{}{}, Revision: 1})
+	g.AddNode(GraphNode{Key: podB, UID: "uid-b", APIVersion: "v1", Properties: map[string]interface{}{"phase": "Succeeded"}, Revision: 1})
+	g.AddRelationship(podA, node1, "runs_on", nil)
+	g.AddRelationship(service, podA, "targets", map[string]string{"ready": "true", "ports": "http:80/TCP"})
+	g.PutRelationship(GraphRelationship{Source: podA, Target: configMap, RelationshipType: "mounts", Qualifier: "settings-volume", Properties: map[string]string{}})
+
+	clock.advance(time.Minute)
+	g.RemoveNode(podB)
+
+	got, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		t.Fatalf("MarshalJSON: %v", err)
+	}
+	got = append(got, '\n')
+
+	golden := filepath.Join("testdata", "graph.golden.json")
+	if *update {
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatalf("writing %s: %v", golden, err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("reading %s: %v", golden, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("MarshalJSON =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,215 @@
+package graph
+
+import "sort"
+
+// Direction selects which relationships of a node a query follows
+type Direction int
+
+const (
+	// DirectionOutgoing follows relationships from the node to their targets
+	DirectionOutgoing Direction = iota
+	// DirectionIncoming follows relationships to the node back to their sources
+	DirectionIncoming
+	// DirectionBoth follows relationships either way
+	DirectionBoth
+)
+
+// ownedBy is the relationship type from an object to its owner
+const ownedBy = "owned_by"
+
+// adjacency is a relationship of a node and the node at its other end
+type adjacency struct {
+	rk       relationshipKey
+	neighbor EntityKey
+}
+
+// Neighbors returns the keys of the nodes one relationship away from key in
+// direction, sorted by key. Only relationships of relTypes are followed, or
+// every relationship if none are given. Endpoints of dangling relationships
+// are included even though they have no node.
+func (g *Graph) Neighbors(key EntityKey, direction Direction, relTypes ...string) []EntityKey {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	neighbors := g.neighbors(key, direction, typeSet(relTypes))
+	sort.Slice(neighbors, func(i, j int) bool {
+		return lessKey(neighbors[i], neighbors[j])
+	})
+	return neighbors
+}
+
+// ShortestPath returns the relationships along a shortest path from a to b,
+// following relationships in either direction, since a path such as
+// Ingress → Service → Pod → Node mixes them. It returns false if b cannot be
+// reached from a, and an empty path if a and b are the same.
+func (g *Graph) ShortestPath(a, b EntityKey) ([]GraphRelationship, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if a == b {
+		return []GraphRelationship{}, true
+	}
+
+	// Breadth-first search, remembering the relationship each node was
+	// first reached through and the node it was reached from
+	via := map[EntityKey]adjacency{}
+	visited := map[EntityKey]struct{}{a: {}}
+	queue := []EntityKey{a}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, adj := range g.adjacent(current, DirectionBoth) {
+			if _, ok := visited[adj.neighbor]; ok {
+				continue
+			}
+			visited[adj.neighbor] = struct{}{}
+			via[adj.neighbor] = adjacency{rk: adj.rk, neighbor: current}
+
+			if adj.neighbor == b {
+				return g.pathTo(a, b, via), true
+			}
+			queue = append(queue, adj.neighbor)
+		}
+	}
+	return nil, false
+}
+
+// Descendants returns the keys of the nodes that key owns directly or
+// transitively through owned_by relationships, such as the ReplicaSets and
+// Pods of a Deployment, sorted by key
+func (g *Graph) Descendants(key EntityKey) []EntityKey {
+	return g.reachable(key, DirectionIncoming, ownedBy)
+}
+
+// Ancestors returns the keys of the nodes that own key directly or
+// transitively through owned_by relationships, such as the ReplicaSet and
+// Deployment of a Pod, sorted by key
+func (g *Graph) Ancestors(key EntityKey) []EntityKey {
+	return g.reachable(key, DirectionOutgoing, ownedBy)
+}
+
+// Subgraph returns a new graph holding copies of the nodes that match
+// filter and of the relationships between them. It has the same dangling
+// edge policy but none of the origins, pending relationships or tombstones
+// of g, and later changes to either graph do not affect the other.
+func (g *Graph) Subgraph(filter func(GraphNode) bool) *Graph {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	sub := NewGraph(WithDanglingPolicy(g.dangling), WithTombstoneRetention(g.tombstoneRetention))
+	sub.now = g.now
+
+	for key, node := range g.nodes {
+		copied := copyNode(node)
+		if filter(copied) {
+			sub.nodes[key] = &copied
+		}
+	}
+
+	for rk, rel := range g.relationships {
+		if !sub.hasNode(rk.source) || !sub.hasNode(rk.target) {
+			continue
+		}
+		copied := copyRelationship(rel)
+		sub.insertRelationship(rk, &copied)
+	}
+	return sub
+}
+
+// reachable returns the keys of the nodes reachable from key in direction
+// through relationships of relTypes, excluding key itself, sorted by key
+func (g *Graph) reachable(key EntityKey, direction Direction, relTypes ...string) []EntityKey {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	types := typeSet(relTypes)
+	visited := map[EntityKey]struct{}{key: {}}
+	queue := []EntityKey{key}
+	var found []EntityKey
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, next := range g.neighbors(current, direction, types) {
+			if _, ok := visited[next]; ok {
+				continue
+			}
+			visited[next] = struct{}{}
+			found = append(found, next)
+			queue = append(queue, next)
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		return lessKey(found[i], found[j])
+	})
+	return found
+}
+
+// neighbors returns the distinct keys one relationship of types away from
+// key in direction, or through any relationship if types is nil.
+// The caller must hold the read lock.
+func (g *Graph) neighbors(key EntityKey, direction Direction, types map[string]struct{}) []EntityKey {
+	seen := make(map[EntityKey]struct{})
+	var neighbors []EntityKey
+	for _, adj := range g.adjacent(key, direction) {
+		if types != nil {
+			if _, ok := types[adj.rk.relationshipType]; !ok {
+				continue
+			}
+		}
+		if _, ok := seen[adj.neighbor]; !ok {
+			seen[adj.neighbor] = struct{}{}
+			neighbors = append(neighbors, adj.neighbor)
+		}
+	}
+	return neighbors
+}
+
+// adjacent returns the relationships of key in direction along with the
+// nodes at their other ends, sorted so that traversals are deterministic.
+// The caller must hold the read lock.
+func (g *Graph) adjacent(key EntityKey, direction Direction) []adjacency {
+	var adjacent []adjacency
+	if direction == DirectionOutgoing || direction == DirectionBoth {
+		for rk := range g.outgoing[key] {
+			adjacent = append(adjacent, adjacency{rk: rk, neighbor: rk.target})
+		}
+	}
+	if direction == DirectionIncoming || direction == DirectionBoth {
+		for rk := range g.incoming[key] {
+			adjacent = append(adjacent, adjacency{rk: rk, neighbor: rk.source})
+		}
+	}
+	sort.Slice(adjacent, func(i, j int) bool {
+		return lessRelationshipKey(adjacent[i].rk, adjacent[j].rk)
+	})
+	return adjacent
+}
+
+// pathTo follows the relationships each node was reached through back from
+// b to a, and returns copies of them in order from a to b.
+// The caller must hold the read lock.
+func (g *Graph) pathTo(a, b EntityKey, via map[EntityKey]adjacency) []GraphRelationship {
+	var path []GraphRelationship
+	for current := b; current != a; current = via[current].neighbor {
+		path = append(path, copyRelationship(g.relationships[via[current].rk]))
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// typeSet returns relTypes as a set, or nil if there are none
+func typeSet(relTypes []string) map[string]struct{} {
+	if len(relTypes) == 0 {
+		return nil
+	}
+	types := make(map[string]struct{}, len(relTypes))
+	for _, relType := range relTypes {
+		types[relType] = struct{}{}
+	}
+	return types
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/AdityaaMK/kubernetes-scraper/extractor"
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	"github.com/AdityaaMK/kubernetes-scraper/k8sclient"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// defaultIngressClass maintains uses_class relationships from Ingresses that
+// name no class to the IngressClass marked as the default, which their
+// controller serves them with. Which class is the default changes with
+// IngressClass events, so the relationships are derived here rather than by
+// the Ingress extractor.
+type defaultIngressClass struct {
+	g         *graph.Graph
+	informers *k8sclient.Informers
+
+	// mu serializes recomputing Ingresses from the two event handlers and resync
+	mu sync.Mutex
+}
+
+func newDefaultIngressClass(g *graph.Graph, informers *k8sclient.Informers) *defaultIngressClass {
+	return &defaultIngressClass{g: g, informers: informers}
+}
+
+// register adds the Ingress and IngressClass event handlers
+func (d *defaultIngressClass) register() error {
+	syncIngress := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if ingress, ok := obj.(*networkingv1.Ingress); ok {
+			d.syncIngress(ingress.Namespace, ingress.Name)
+		}
+	}
+	err := d.informers.AddEventHandler("Ingress", cache.ResourceEventHandlerFuncs{
+		AddFunc:    syncIngress,
+		UpdateFunc: func(_, newObj interface{}) { syncIngress(newObj) },
+		DeleteFunc: syncIngress,
+	})
+	if err != nil {
+		return err
+	}
+
+	// A class change only matters if it is or was marked as the default
+	syncClass := func(objs ...interface{}) {
+		for _, obj := range objs {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if class, ok := obj.(*networkingv1.IngressClass); ok && extractor.IsDefaultIngressClass(class) {
+				d.resync()
+				return
+			}
+		}
+	}
+	return d.informers.AddEventHandler("IngressClass", cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { syncClass(obj) },
+		UpdateFunc: func(oldObj, newObj interface{}) { syncClass(oldObj, newObj) },
+		DeleteFunc: func(obj interface{}) { syncClass(obj) },
+	})
+}
+
+// resync recomputes every Ingress
+func (d *defaultIngressClass) resync() {
+	for _, obj := range d.informers.Indexer("Ingress").List() {
+		if ingress, ok := obj.(*networkingv1.Ingress); ok {
+			d.syncIngress(ingress.Namespace, ingress.Name)
+		}
+	}
+}
+
+// syncIngress relates an Ingress that names no class to the default class,
+// or releases its relationship if it names one, has been deleted or there is
+// no single default
+func (d *defaultIngressClass) syncIngress(namespace, name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := graph.EntityKey{Name: name, Namespace: namespace, Type: "Ingress"}
+	origin := "default-class:" + key.String()
+
+	obj, exists, err := d.informers.Indexer("Ingress").GetByKey(namespace + "/" + name)
+	if err != nil {
+		log.Printf("Error getting Ingress %s/%s: %v", namespace, name, err)
+		return
+	}
+	ingress, ok := obj.(*networkingv1.Ingress)
+	if !exists || !ok || extractor.IngressClassName(ingress) != "" {
+		d.g.SyncRelationships(origin, nil)
+		return
+	}
+
+	className, ok := d.defaultClass()
+	if !ok {
+		d.g.SyncRelationships(origin, nil)
+		return
+	}
+	d.g.SyncRelationships(origin, []graph.GraphRelationship{extractor.IngressClassRelationship(key, className, true)})
+}
+
+// defaultClass returns the name of the IngressClass marked as the default.
+// Marking several classes as the default is a misconfiguration the API
+// server refuses classless Ingresses for, so it returns false then.
+func (d *defaultIngressClass) defaultClass() (string, bool) {
+	var defaults []string
+	for _, obj := range d.informers.Indexer("IngressClass").List() {
+		if class, ok := obj.(*networkingv1.IngressClass); ok && extractor.IsDefaultIngressClass(class) {
+			defaults = append(defaults, class.Name)
+		}
+	}
+	if len(defaults) != 1 {
+		return "", false
+	}
+	return defaults[0], true
+}
@@ -1,22 +1,24 @@
 package k8sclient
 
 import (
-	"context"
 	"log"
 	"path/filepath"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/homedir"
 )
 
-// K8sClient wraps the kubernetes clientset
+// K8sClient wraps the kubernetes clientset, along with a dynamic client and
+// a discovery-backed REST mapper for resources without a typed API
 type K8sClient struct {
-	clientset *kubernetes.Clientset
+	clientset kubernetes.Interface
+	dynamic   dynamic.Interface
+	mapper    *restmapper.DeferredDiscoveryRESTMapper
 }
 
 // NewK8sClient creates a new Kubernetes client
@@ -44,151 +46,20 @@ func NewK8sClient() (*K8sClient, error) {
 		return nil, err
 	}
 
-	return &K8sClient{
-		clientset: clientset,
-	}, nil
-}
-
-// Convert a runtime.Object to a map[string]interface{}
-func ConvertToMap(obj interface{}) (map[string]interface{}, error) {
-	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
-}
-
-// ListPods lists all pods in all namespaces
-func (c *K8sClient) ListPods(ctx context.Context) ([]interface{}, error) {
-	pods, err := c.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, err
-	}
-
-	// Convert to []interface{} with maps
-	result := make([]interface{}, len(pods.Items))
-	for i, pod := range pods.Items {
-		podMap, err := ConvertToMap(&pod)
-		if err != nil {
-			return nil, err
-		}
-		result[i] = podMap
-	}
-	return result, nil
-}
-
-// ListReplicaSets lists all replicasets in all namespaces
-func (c *K8sClient) ListReplicaSets(ctx context.Context) ([]interface{}, error) {
-	replicasets, err := c.clientset.AppsV1().ReplicaSets("").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, err
-	}
-
-	result := make([]interface{}, len(replicasets.Items))
-	for i, rs := range replicasets.Items {
-		rsMap, err := ConvertToMap(&rs)
-		if err != nil {
-			return nil, err
-		}
-		result[i] = rsMap
-	}
-	return result, nil
-}
-
-// ListDeployments lists all deployments in all namespaces
-func (c *K8sClient) ListDeployments(ctx context.Context) ([]interface{}, error) {
-	deployments, err := c.clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{})
+	dynamicClient, err := dynamic.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
 
-	result := make([]interface{}, len(deployments.Items))
-	for i, deployment := range deployments.Items {
-		deploymentMap, err := ConvertToMap(&deployment)
-		if err != nil {
-			return nil, err
-		}
-		result[i] = deploymentMap
-	}
-	return result, nil
+	return NewK8sClientForClientsets(clientset, dynamicClient), nil
 }
 
-// ListNodes lists all nodes
-func (c *K8sClient) ListNodes(ctx context.Context) ([]interface{}, error) {
-	nodes, err := c.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, err
-	}
-
-	result := make([]interface{}, len(nodes.Items))
-	for i, node := range nodes.Items {
-		nodeMap, err := ConvertToMap(&node)
-		if err != nil {
-			return nil, err
-		}
-		result[i] = nodeMap
-	}
-	return result, nil
-}
-
-// ListServices lists all services in all namespaces
-func (c *K8sClient) ListServices(ctx context.Context) ([]interface{}, error) {
-	services, err := c.clientset.CoreV1().Services("").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, err
-	}
-
-	result := make([]interface{}, len(services.Items))
-	for i, service := range services.Items {
-		serviceMap, err := ConvertToMap(&service)
-		if err != nil {
-			return nil, err
-		}
-		result[i] = serviceMap
-	}
-	return result, nil
-}
-
-// ListConfigMaps lists all configmaps in all namespaces
-func (c *K8sClient) ListConfigMaps(ctx context.Context) ([]interface{}, error) {
-	configmaps, err := c.clientset.CoreV1().ConfigMaps("").List(ctx, metav1.ListOptions{})
-	if err != nil {
-		return nil, err
-	}
-
-	result := make([]interface{}, len(configmaps.Items))
-	for i, configmap := range configmaps.Items {
-		configmapMap, err := ConvertToMap(&configmap)
-		if err != nil {
-			return nil, err
-		}
-		result[i] = configmapMap
+// NewK8sClientForClientsets creates a Kubernetes client over existing
+// clientsets, such as the fakes of client-go in tests
+func NewK8sClientForClientsets(clientset kubernetes.Interface, dynamicClient dynamic.Interface) *K8sClient {
+	return &K8sClient{
+		clientset: clientset,
+		dynamic:   dynamicClient,
+		mapper:    restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery())),
 	}
-	return result, nil
-}
-
-// WatchPods watches for pod events
-func (c *K8sClient) WatchPods(ctx context.Context) (watch.Interface, error) {
-	return c.clientset.CoreV1().Pods("").Watch(ctx, metav1.ListOptions{})
-}
-
-// WatchReplicaSets watches for replicaset events
-func (c *K8sClient) WatchReplicaSets(ctx context.Context) (watch.Interface, error) {
-	return c.clientset.AppsV1().ReplicaSets("").Watch(ctx, metav1.ListOptions{})
-}
-
-// WatchDeployments watches for deployment events
-func (c *K8sClient) WatchDeployments(ctx context.Context) (watch.Interface, error) {
-	return c.clientset.AppsV1().Deployments("").Watch(ctx, metav1.ListOptions{})
-}
-
-// WatchNodes watches for node events
-func (c *K8sClient) WatchNodes(ctx context.Context) (watch.Interface, error) {
-	return c.clientset.CoreV1().Nodes().Watch(ctx, metav1.ListOptions{})
-}
-
-// WatchServices watches for service events
-func (c *K8sClient) WatchServices(ctx context.Context) (watch.Interface, error) {
-	return c.clientset.CoreV1().Services("").Watch(ctx, metav1.ListOptions{})
-}
-
-// WatchConfigMaps watches for configmap events
-func (c *K8sClient) WatchConfigMaps(ctx context.Context) (watch.Interface, error) {
-	return c.clientset.CoreV1().ConfigMaps("").Watch(ctx, metav1.ListOptions{})
 }
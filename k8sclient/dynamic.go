@@ -0,0 +1,51 @@
+package k8sclient
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// DynamicResource is a resource served through the dynamic client, such as
+// a custom resource, along with the kind of its objects. Type is the
+// resource type its informer is registered as; it defaults to the kind, and
+// callers qualify it to keep apart kinds of different groups sharing a name.
+type DynamicResource struct {
+	Resource schema.GroupVersionResource
+	Kind     string
+	Type     string
+}
+
+// Discover resolves resource against the API server's discovery information.
+// The version may be left empty to use the group's preferred version, and
+// the resource may be given in its plural or singular form.
+func (c *K8sClient) Discover(resource schema.GroupVersionResource) (DynamicResource, error) {
+	resolved, err := c.mapper.ResourceFor(resource)
+	if err != nil {
+		return DynamicResource{}, fmt.Errorf("error discovering %s: %v", resource.String(), err)
+	}
+
+	kind, err := c.mapper.KindFor(resolved)
+	if err != nil {
+		return DynamicResource{}, fmt.Errorf("error discovering kind of %s: %v", resolved.String(), err)
+	}
+
+	return DynamicResource{Resource: resolved, Kind: kind.Kind, Type: kind.Kind}, nil
+}
+
+// IsNamespaced reports whether objects of kind, in the group version named
+// by apiVersion, are namespaced, as resolved through API discovery. The
+// discovery information is cached, so kinds whose CustomResourceDefinition
+// is created later are not found.
+func (c *K8sClient) IsNamespaced(apiVersion, kind string) (bool, error) {
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return false, err
+	}
+	mapping, err := c.mapper.RESTMapping(gv.WithKind(kind).GroupKind(), gv.Version)
+	if err != nil {
+		return false, err
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+}
@@ -0,0 +1,149 @@
+package k8sclient
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Informers is a shared-informer backend for all supported resource types.
+//
+// Each informer lists its resource once and then watches from the returned
+// resourceVersion with bookmarks enabled, so reconnecting after a watch
+// timeout resumes where it left off. When the resourceVersion has been
+// compacted away ("resource version too old") the informer relists and
+// reconciles its cache, delivering the differences as events. Every resync
+// period all cached objects are redelivered as updates.
+//
+// Dynamic resources are watched through the dynamic client and deliver
+// their objects as *unstructured.Unstructured.
+type Informers struct {
+	factory        informers.SharedInformerFactory
+	dynamicFactory dynamicinformer.DynamicSharedInformerFactory
+	informers      map[string]cache.SharedIndexInformer
+}
+
+// NewInformers creates shared informers for every supported resource type
+// and for each of the dynamic resources, keyed by their Type
+func (c *K8sClient) NewInformers(resync time.Duration, dynamicResources []DynamicResource) (*Informers, error) {
+	factory := informers.NewSharedInformerFactory(c.clientset, resync)
+	dynamicFactory := dynamicinformer.NewDynamicSharedInformerFactory(c.dynamic, resync)
+
+	i := &Informers{
+		factory:        factory,
+		dynamicFactory: dynamicFactory,
+		informers: map[string]cache.SharedIndexInformer{
+			"Pod":                     factory.Core().V1().Pods().Informer(),
+			"ReplicaSet":              factory.Apps().V1().ReplicaSets().Informer(),
+			"Deployment":              factory.Apps().V1().Deployments().Informer(),
+			"StatefulSet":             factory.Apps().V1().StatefulSets().Informer(),
+			"DaemonSet":               factory.Apps().V1().DaemonSets().Informer(),
+			"Job":                     factory.Batch().V1().Jobs().Informer(),
+			"CronJob":                 factory.Batch().V1().CronJobs().Informer(),
+			"Node":                    factory.Core().V1().Nodes().Informer(),
+			"Namespace":               factory.Core().V1().Namespaces().Informer(),
+			"Service":                 factory.Core().V1().Services().Informer(),
+			"EndpointSlice":           factory.Discovery().V1().EndpointSlices().Informer(),
+			"ConfigMap":               factory.Core().V1().ConfigMaps().Informer(),
+			"Secret":                  factory.Core().V1().Secrets().Informer(),
+			"PersistentVolumeClaim":   factory.Core().V1().PersistentVolumeClaims().Informer(),
+			"PersistentVolume":        factory.Core().V1().PersistentVolumes().Informer(),
+			"StorageClass":            factory.Storage().V1().StorageClasses().Informer(),
+			"Ingress":                 factory.Networking().V1().Ingresses().Informer(),
+			"IngressClass":            factory.Networking().V1().IngressClasses().Informer(),
+			"NetworkPolicy":           factory.Networking().V1().NetworkPolicies().Informer(),
+			"ServiceAccount":          factory.Core().V1().ServiceAccounts().Informer(),
+			"Role":                    factory.Rbac().V1().Roles().Informer(),
+			"ClusterRole":             factory.Rbac().V1().ClusterRoles().Informer(),
+			"RoleBinding":             factory.Rbac().V1().RoleBindings().Informer(),
+			"ClusterRoleBinding":      factory.Rbac().V1().ClusterRoleBindings().Informer(),
+			"HorizontalPodAutoscaler": factory.Autoscaling().V2().HorizontalPodAutoscalers().Informer(),
+			"PodDisruptionBudget":     factory.Policy().V1().PodDisruptionBudgets().Informer(),
+		},
+	}
+
+	for _, resource := range dynamicResources {
+		if _, ok := i.informers[resource.Type]; ok {
+			return nil, fmt.Errorf("resource %s has type %s, which is already watched", resource.Resource.String(), resource.Type)
+		}
+		i.informers[resource.Type] = dynamicFactory.ForResource(resource.Resource).Informer()
+	}
+
+	for resourceType, informer := range i.informers {
+		if err := informer.SetWatchErrorHandler(watchErrorHandler(resourceType)); err != nil {
+			return nil, fmt.Errorf("error configuring %s informer: %v", resourceType, err)
+		}
+	}
+
+	return i, nil
+}
+
+// watchErrorHandler logs watch failures for a resource type. The reflector
+// relists on its own after any watch error; expired resource versions are
+// called out separately since they force a full relist.
+func watchErrorHandler(resourceType string) cache.WatchErrorHandler {
+	return func(r *cache.Reflector, err error) {
+		if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+			log.Printf("%s watch resource version too old, relisting: %v", resourceType, err)
+			return
+		}
+		cache.DefaultWatchErrorHandler(r, err)
+	}
+}
+
+// ResourceTypes returns the resource types served by the informers, sorted by name
+func (i *Informers) ResourceTypes() []string {
+	types := make([]string, 0, len(i.informers))
+	for resourceType := range i.informers {
+		types = append(types, resourceType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// AddEventHandler registers a handler for events on the given resource type
+func (i *Informers) AddEventHandler(resourceType string, handler cache.ResourceEventHandler) error {
+	informer, ok := i.informers[resourceType]
+	if !ok {
+		return fmt.Errorf("no informer for resource type %s", resourceType)
+	}
+	_, err := informer.AddEventHandler(handler)
+	return err
+}
+
+// Indexer returns the local cache backing the informer for the given resource type
+func (i *Informers) Indexer(resourceType string) cache.Indexer {
+	informer, ok := i.informers[resourceType]
+	if !ok {
+		return nil
+	}
+	return informer.GetIndexer()
+}
+
+// Start starts all informers. They stop when ctx is cancelled.
+func (i *Informers) Start(ctx context.Context) {
+	i.factory.Start(ctx.Done())
+	i.dynamicFactory.Start(ctx.Done())
+}
+
+// WaitForCacheSync blocks until every informer has delivered its initial list
+func (i *Informers) WaitForCacheSync(ctx context.Context) bool {
+	for _, synced := range i.factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return false
+		}
+	}
+	for _, synced := range i.dynamicFactory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return false
+		}
+	}
+	return true
+}
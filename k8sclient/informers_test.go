@@ -0,0 +1,111 @@
+package k8sclient
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/AdityaaMK/kubernetes-scraper/extractor"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestClient() *K8sClient {
+	return NewK8sClientForClientsets(fake.NewSimpleClientset(), dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()))
+}
+
+// dynamicResource returns a resource with its type qualified the way
+// dynamic resources are before their informers are created
+func dynamicResource(group, version, resource, kind string) DynamicResource {
+	return DynamicResource{
+		Resource: schema.GroupVersionResource{Group: group, Version: version, Resource: resource},
+		Kind:     kind,
+		Type:     extractor.TypeName(group, kind),
+	}
+}
+
+func TestNewInformers(t *testing.T) {
+	knativeService := dynamicResource("serving.knative.dev", "v1", "services", "Service")
+	rollout := dynamicResource("argoproj.io", "v1alpha1", "rollouts", "Rollout")
+
+	informers, err := newTestClient().NewInformers(0, []DynamicResource{knativeService, rollout})
+	if err != nil {
+		t.Fatalf("NewInformers() error = %v", err)
+	}
+
+	// The Knative Service is watched alongside the built-in one
+	types := informers.ResourceTypes()
+	watched := make(map[string]bool, len(types))
+	for _, resourceType := range types {
+		watched[resourceType] = true
+	}
+	for _, resourceType := range []string{"Pod", "Service", "Service.serving.knative.dev", "Rollout.argoproj.io"} {
+		if !watched[resourceType] {
+			t.Errorf("ResourceTypes() = %v, missing %s", types, resourceType)
+		}
+	}
+	for i := 1; i < len(types); i++ {
+		if types[i-1] >= types[i] {
+			t.Errorf("ResourceTypes() not sorted: %s before %s", types[i-1], types[i])
+		}
+	}
+
+	// Each type has its own cache
+	service := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	knative := &unstructured.Unstructured{}
+	knative.SetAPIVersion("serving.knative.dev/v1")
+	knative.SetKind("Service")
+	knative.SetNamespace("default")
+	knative.SetName("web")
+	if err := informers.Indexer("Service").Add(service); err != nil {
+		t.Fatalf("adding Service: %v", err)
+	}
+	if err := informers.Indexer("Service.serving.knative.dev").Add(knative); err != nil {
+		t.Fatalf("adding Knative Service: %v", err)
+	}
+	for resourceType, want := range map[string]interface{}{"Service": service, "Service.serving.knative.dev": knative} {
+		obj, exists, err := informers.Indexer(resourceType).GetByKey("default/web")
+		if err != nil || !exists || obj != want {
+			t.Errorf("Indexer(%q).GetByKey() = %v, %t, %v, want %v", resourceType, obj, exists, err, want)
+		}
+	}
+
+	if indexer := informers.Indexer("Service.example.com"); indexer != nil {
+		t.Errorf("Indexer() of an unwatched type = %v, want nil", indexer)
+	}
+	if err := informers.AddEventHandler("Service.example.com", nil); err == nil {
+		t.Errorf("AddEventHandler() of an unwatched type succeeded")
+	}
+}
+
+func TestNewInformersTypeClash(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources []DynamicResource
+	}{
+		{
+			name:      "unqualified built-in kind",
+			resources: []DynamicResource{{Resource: schema.GroupVersionResource{Group: "serving.knative.dev", Version: "v1", Resource: "services"}, Kind: "Service", Type: "Service"}},
+		},
+		{
+			name: "resource listed twice",
+			resources: []DynamicResource{
+				dynamicResource("argoproj.io", "v1alpha1", "rollouts", "Rollout"),
+				dynamicResource("argoproj.io", "v1", "rollouts", "Rollout"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestClient().NewInformers(0, tt.resources)
+			if err == nil || !strings.Contains(err.Error(), "already watched") {
+				t.Errorf("NewInformers() error = %v, want a clash", err)
+			}
+		})
+	}
+}
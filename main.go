@@ -2,29 +2,62 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
-	"fmt"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 
+	"github.com/AdityaaMK/kubernetes-scraper/extractor"
 	"github.com/AdityaaMK/kubernetes-scraper/graph"
 	"github.com/AdityaaMK/kubernetes-scraper/k8sclient"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/tools/cache"
 )
 
-// Global maps to track pods and services for dynamic relationship updates
-var (
-	podCache     = make(map[string]map[string]interface{})
-	serviceCache = make(map[string]map[string]interface{})
-	cacheMutex   = sync.RWMutex{}
-)
+// secretHashKeyEnv names the environment variable holding the key for the
+// contentHash of Secret nodes
+const secretHashKeyEnv = "SECRET_HASH_KEY"
+
+// resyncPeriod is how often informers redeliver every cached object, which
+// re-derives all relationships from the current state of the cluster
+const resyncPeriod = 10 * time.Minute
 
 func main() {
+	danglingEdges := flag.String("dangling-edges", graph.DanglingKeep.String(),
+		"how to handle relationships to nodes that have not been seen yet: keep, drop or defer; relationships to deleted nodes are always removed, and restored on recreation unless drop")
+	serviceTargetsMode := flag.String("service-targets", targetsFromEndpointSlices,
+		"how to find the pods a Service targets: endpointslices, or labels to match selectors against pod labels")
+	schedulingEdges := flag.Bool("scheduling-edges", false,
+		"maintain schedulable_on relationships from each pod to every node it can be scheduled on, at the cost of evaluating every pod against every node")
+	resourcesFile := flag.String("resources", "",
+		"path of a YAML file listing additional resources, such as custom resources, to scrape through the dynamic client")
+	tombstoneRetention := flag.Duration("tombstone-retention", time.Hour,
+		"how long to keep deleted nodes, and the old incarnations of recreated objects, as tombstones in the graph; 0 disables tombstones")
+	propertiesFile := flag.String("properties", "",
+		"path of a YAML file mapping kinds to additional node properties, each a JSONPath expression and a type")
+	flag.Parse()
+
+	danglingPolicy, err := graph.ParseDanglingPolicy(*danglingEdges)
+	if err != nil {
+		log.Fatalf("Invalid -dangling-edges: %v", err)
+	}
+
+	// Key Secret content hashes so they cannot be used to test guesses of
+	// the values. A random key keeps them private but only stable for this run.
+	secretHashKey := []byte(os.Getenv(secretHashKeyEnv))
+	if len(secretHashKey) == 0 {
+		secretHashKey = make([]byte, 32)
+		if _, err := rand.Read(secretHashKey); err != nil {
+			log.Fatalf("Error generating Secret hash key: %v", err)
+		}
+		log.Printf("%s is not set; Secret content hashes will change on restart", secretHashKeyEnv)
+	}
+	extractor.SetSecretHashKey(secretHashKey)
+
 	// Create a context that we can cancel
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -35,440 +68,220 @@ func main() {
 		log.Fatalf("Error creating Kubernetes client: %v", err)
 	}
 
+	// Resolve the scope of owners of any kind, including custom resources,
+	// through API discovery
+	extractor.SetScopeResolver(client.IsNamespaced)
+
 	// Create graph
-	g := graph.NewGraph()
+	g := graph.NewGraph(graph.WithDanglingPolicy(danglingPolicy), graph.WithTombstoneRetention(*tombstoneRetention))
 
-	// List all resources
-	if err := listAllResources(ctx, client, g); err != nil {
-		log.Printf("Error listing resources: %v", err)
+	// Discover the additional resources to scrape
+	var dynamicResources []k8sclient.DynamicResource
+	if *resourcesFile != "" {
+		dynamicResources, err = loadDynamicResources(client, *resourcesFile)
+		if err != nil {
+			log.Fatalf("Invalid -resources: %v", err)
+		}
 	}
 
-	// Watch all resources
-	go watchAllResources(ctx, client, g)
-
-	// Emit graph periodically
-	go emitGraph(ctx, g)
-
-	// Wait for interrupt signal
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
-
-	log.Println("Shutting down...")
-}
-
-func listAllResources(ctx context.Context, client *k8sclient.K8sClient, g *graph.Graph) error {
-	// List Pods
-	pods, err := client.ListPods(ctx)
-	if err != nil {
-		return fmt.Errorf("error listing pods: %v", err)
-	}
-	cacheMutex.Lock()
-	for _, pod := range pods {
-		podObj := pod.(map[string]interface{})
-		podName := podObj["metadata"].(map[string]interface{})["name"].(string)
-		podNamespace := podObj["metadata"].(map[string]interface{})["namespace"].(string)
-		podCache[fmt.Sprintf("%s/%s", podNamespace, podName)] = podObj
-	}
-	cacheMutex.Unlock()
-	for _, pod := range pods {
-		g.AddNode(pod)
+	// Add the configured node properties, including those of the additional resources
+	if *propertiesFile != "" {
+		if err := loadPropertySchema(*propertiesFile); err != nil {
+			log.Fatalf("Invalid -properties: %v", err)
+		}
 	}
 
-	// List ReplicaSets
-	replicasets, err := client.ListReplicaSets(ctx)
+	// Create informers for all resources
+	informers, err := client.NewInformers(resyncPeriod, dynamicResources)
 	if err != nil {
-		return fmt.Errorf("error listing replicasets: %v", err)
-	}
-	for _, rs := range replicasets {
-		g.AddNode(rs)
+		log.Fatalf("Error creating informers: %v", err)
 	}
 
-	// List Deployments
-	deployments, err := client.ListDeployments(ctx)
-	if err != nil {
-		return fmt.Errorf("error listing deployments: %v", err)
-	}
-	for _, deployment := range deployments {
-		g.AddNode(deployment)
+	// Drive graph updates from informer events for every resource type
+	// that becomes a node
+	for _, resourceType := range informers.ResourceTypes() {
+		if _, ok := extractor.For(resourceType); !ok {
+			continue
+		}
+		if err := informers.AddEventHandler(resourceType, graphEventHandler(g, resourceType)); err != nil {
+			log.Fatalf("Error registering %s event handler: %v", resourceType, err)
+		}
 	}
 
-	// List Nodes
-	nodes, err := client.ListNodes(ctx)
+	// Maintain Service -> Pod relationships
+	targets, err := newServiceTargets(*serviceTargetsMode, g, informers)
 	if err != nil {
-		return fmt.Errorf("error listing nodes: %v", err)
+		log.Fatalf("Invalid -service-targets: %v", err)
 	}
-	for _, node := range nodes {
-		g.AddNode(node)
+	if err := targets.register(); err != nil {
+		log.Fatalf("Error registering service targets event handlers: %v", err)
 	}
 
-	// List Services
-	services, err := client.ListServices(ctx)
-	if err != nil {
-		return fmt.Errorf("error listing services: %v", err)
-	}
-	cacheMutex.Lock()
-	for _, service := range services {
-		serviceObj := service.(map[string]interface{})
-		serviceName := serviceObj["metadata"].(map[string]interface{})["name"].(string)
-		serviceNamespace := serviceObj["metadata"].(map[string]interface{})["namespace"].(string)
-		serviceCache[fmt.Sprintf("%s/%s", serviceNamespace, serviceName)] = serviceObj
-	}
-	cacheMutex.Unlock()
-	for _, service := range services {
-		g.AddNode(service)
+	// Maintain Ingress -> IngressClass relationships of Ingresses that name no class
+	ingressClasses := newDefaultIngressClass(g, informers)
+	if err := ingressClasses.register(); err != nil {
+		log.Fatalf("Error registering default IngressClass event handlers: %v", err)
 	}
 
-	// List ConfigMaps
-	configmaps, err := client.ListConfigMaps(ctx)
-	if err != nil {
-		return fmt.Errorf("error listing configmaps: %v", err)
-	}
-	for _, configmap := range configmaps {
-		g.AddNode(configmap)
+	// Maintain StatefulSet -> PersistentVolumeClaim relationships
+	claims := newStatefulSetClaims(g, informers)
+	if err := claims.register(); err != nil {
+		log.Fatalf("Error registering StatefulSet claims event handlers: %v", err)
 	}
 
-	// Create relationships
-	for _, pod := range pods {
-		podObj := pod.(map[string]interface{})
-		podName := podObj["metadata"].(map[string]interface{})["name"].(string)
-		podNamespace := podObj["metadata"].(map[string]interface{})["namespace"].(string)
-		nodeName := podObj["spec"].(map[string]interface{})["nodeName"].(string)
-
-		// Get ownerReferences with nil check
-		ownerRefs := []interface{}{}
-		if ownerRefsInterface, ok := podObj["metadata"].(map[string]interface{})["ownerReferences"]; ok && ownerRefsInterface != nil {
-			ownerRefs = ownerRefsInterface.([]interface{})
-		}
+	// Maintain PodDisruptionBudget -> Pod relationships
+	protects := newSelectorRelationships(g, informers, "PodDisruptionBudget", "protects", podDisruptionBudgetSelector)
+	if err := protects.register(); err != nil {
+		log.Fatalf("Error registering PodDisruptionBudget event handlers: %v", err)
+	}
 
-		// Pod -> Node relationship
-		if nodeName != "" {
-			g.AddRelationship(
-				graph.EntityKey{Name: podName, Namespace: podNamespace, Type: "Pod"},
-				graph.EntityKey{Name: nodeName, Type: "Node"},
-				"runs_on",
-				nil,
-			)
-		}
+	// Maintain NetworkPolicy -> Pod and NetworkPolicy -> peer reachability relationships
+	policies := newNetworkPolicyRelationships(g, informers)
+	if err := policies.register(); err != nil {
+		log.Fatalf("Error registering NetworkPolicy event handlers: %v", err)
+	}
 
-		// Pod -> ReplicaSet relationship
-		for _, ownerRef := range ownerRefs {
-			owner := ownerRef.(map[string]interface{})
-			if owner["kind"].(string) == "ReplicaSet" {
-				g.AddRelationship(
-					graph.EntityKey{Name: podName, Namespace: podNamespace, Type: "Pod"},
-					graph.EntityKey{Name: owner["name"].(string), Namespace: podNamespace, Type: "ReplicaSet"},
-					"owned_by",
-					nil,
-				)
-			}
+	// Maintain Pod -> Node scheduling constraint relationships
+	var scheduling *schedulingRelationships
+	if *schedulingEdges {
+		scheduling = newSchedulingRelationships(g, informers)
+		if err := scheduling.register(); err != nil {
+			log.Fatalf("Error registering scheduling event handlers: %v", err)
 		}
 	}
 
-	for _, rs := range replicasets {
-		rsObj := rs.(map[string]interface{})
-		rsName := rsObj["metadata"].(map[string]interface{})["name"].(string)
-		rsNamespace := rsObj["metadata"].(map[string]interface{})["namespace"].(string)
-		ownerRefs := rsObj["metadata"].(map[string]interface{})["ownerReferences"].([]interface{})
-
-		// ReplicaSet -> Deployment relationship
-		for _, ownerRef := range ownerRefs {
-			owner := ownerRef.(map[string]interface{})
-			if owner["kind"].(string) == "Deployment" {
-				g.AddRelationship(
-					graph.EntityKey{Name: rsName, Namespace: rsNamespace, Type: "ReplicaSet"},
-					graph.EntityKey{Name: owner["name"].(string), Namespace: rsNamespace, Type: "Deployment"},
-					"owned_by",
-					nil,
-				)
-			}
-		}
+	informers.Start(ctx)
+	if !informers.WaitForCacheSync(ctx) {
+		log.Printf("Error waiting for informer caches to sync")
+	}
+	targets.resync()
+	ingressClasses.resync()
+	claims.resync()
+	protects.resync()
+	policies.resync()
+	if scheduling != nil {
+		scheduling.resync()
 	}
+	go policies.run(ctx)
 
-	for _, service := range services {
-		serviceObj := service.(map[string]interface{})
-		serviceName := serviceObj["metadata"].(map[string]interface{})["name"].(string)
-		serviceNamespace := serviceObj["metadata"].(map[string]interface{})["namespace"].(string)
+	// Emit graph periodically
+	go emitGraph(ctx, g)
 
-		// Get service selector with nil check
-		selector := make(map[string]interface{})
-		if selectorInterface, ok := serviceObj["spec"].(map[string]interface{})["selector"]; ok && selectorInterface != nil {
-			selector = selectorInterface.(map[string]interface{})
-		}
+	// Wait for interrupt signal
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
 
-		// Service -> Pod relationships based on selector
-		for _, pod := range pods {
-			podObj := pod.(map[string]interface{})
-			podName := podObj["metadata"].(map[string]interface{})["name"].(string)
-			podNamespace := podObj["metadata"].(map[string]interface{})["namespace"].(string)
+	log.Println("Shutting down...")
+}
 
-			// Get pod labels with nil check
-			podLabels := make(map[string]interface{})
-			if labelsInterface, ok := podObj["metadata"].(map[string]interface{})["labels"]; ok && labelsInterface != nil {
-				podLabels = labelsInterface.(map[string]interface{})
+// graphEventHandler applies informer events for a resource type to the graph
+func graphEventHandler(g *graph.Graph, resourceType string) cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			result, err := applyObject(g, resourceType, obj)
+			if err != nil {
+				log.Printf("Error applying %s: %v", resourceType, err)
+				return
 			}
-
-			// Check if pod labels match service selector
-			matches := true
-			for key, value := range selector {
-				if podLabels[key] != value {
-					matches = false
-					break
+			log.Printf("%s added: %v", resourceType, result.Node.Key.Name)
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// An object deleted and recreated while the watch was down is
+			// delivered as an update; remove the old incarnation first
+			if isRecreated(oldObj, newObj) {
+				if _, err := removeObject(g, resourceType, oldObj); err != nil {
+					log.Printf("Error removing %s: %v", resourceType, err)
 				}
 			}
-
-			if matches {
-				g.AddRelationship(
-					graph.EntityKey{Name: serviceName, Namespace: serviceNamespace, Type: "Service"},
-					graph.EntityKey{Name: podName, Namespace: podNamespace, Type: "Pod"},
-					"targets",
-					nil,
-				)
+			result, err := applyObject(g, resourceType, newObj)
+			if err != nil {
+				log.Printf("Error applying %s: %v", resourceType, err)
+				return
 			}
-		}
-	}
-
-	for _, deployment := range deployments {
-		deploymentObj := deployment.(map[string]interface{})
-		deploymentName := deploymentObj["metadata"].(map[string]interface{})["name"].(string)
-		deploymentNamespace := deploymentObj["metadata"].(map[string]interface{})["namespace"].(string)
-		volumes := deploymentObj["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["volumes"].([]interface{})
-
-		// Deployment -> ConfigMap relationships
-		for _, volume := range volumes {
-			vol := volume.(map[string]interface{})
-			if configMap, ok := vol["configMap"]; ok {
-				configMapName := configMap.(map[string]interface{})["name"].(string)
-				g.AddRelationship(
-					graph.EntityKey{Name: deploymentName, Namespace: deploymentNamespace, Type: "Deployment"},
-					graph.EntityKey{Name: configMapName, Namespace: deploymentNamespace, Type: "ConfigMap"},
-					"uses",
-					nil,
-				)
+			// Periodic resyncs redeliver unchanged objects; only log real changes
+			if !isResync(oldObj, newObj) {
+				log.Printf("%s modified: %v", resourceType, result.Node.Key.Name)
 			}
-		}
+		},
+		DeleteFunc: func(obj interface{}) {
+			// The final state of an object deleted while the watch was down
+			// is wrapped in a tombstone
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			result, err := removeObject(g, resourceType, obj)
+			if err != nil {
+				log.Printf("Error removing %s: %v", resourceType, err)
+				return
+			}
+			log.Printf("%s deleted: %v", resourceType, result.Node.Key.Name)
+		},
 	}
-
-	return nil
 }
 
-func watchAllResources(ctx context.Context, client *k8sclient.K8sClient, g *graph.Graph) {
-	// Watch Pods
-	go watchResource(ctx, client.WatchPods, g, "Pod")
-
-	// Watch ReplicaSets
-	go watchResource(ctx, client.WatchReplicaSets, g, "ReplicaSet")
-
-	// Watch Deployments
-	go watchResource(ctx, client.WatchDeployments, g, "Deployment")
-
-	// Watch Nodes
-	go watchResource(ctx, client.WatchNodes, g, "Node")
-
-	// Watch Services
-	go watchResource(ctx, client.WatchServices, g, "Service")
-
-	// Watch ConfigMaps
-	go watchResource(ctx, client.WatchConfigMaps, g, "ConfigMap")
+// isResync reports whether an update event carries an unchanged object
+func isResync(oldObj, newObj interface{}) bool {
+	oldMeta, err := meta.Accessor(oldObj)
+	if err != nil {
+		return false
+	}
+	newMeta, err := meta.Accessor(newObj)
+	if err != nil {
+		return false
+	}
+	return oldMeta.GetResourceVersion() == newMeta.GetResourceVersion()
 }
 
-func watchResource(ctx context.Context, watchFunc func(context.Context) (watch.Interface, error), g *graph.Graph, resourceType string) {
-	for {
-		watcher, err := watchFunc(ctx)
-		if err != nil {
-			log.Printf("Error watching %s: %v", resourceType, err)
-			time.Sleep(5 * time.Second)
-			continue
-		}
-
-		for {
-			select {
-			case <-ctx.Done():
-				watcher.Stop()
-				return
-			case event, ok := <-watcher.ResultChan():
-				if !ok {
-					log.Printf("%s watcher closed", resourceType)
-					return
-				}
-
-				// Convert runtime.Object to unstructured.Unstructured
-				unstructuredObj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(event.Object)
-				if err != nil {
-					log.Printf("Error converting object to unstructured: %v", err)
-					continue
-				}
-
-				metadata := unstructuredObj["metadata"].(map[string]interface{})
-				name := metadata["name"].(string)
-
-				// Get namespace with nil check
-				namespace := ""
-				if namespaceInterface, ok := metadata["namespace"]; ok && namespaceInterface != nil {
-					namespace = namespaceInterface.(string)
-				}
-
-				switch event.Type {
-				case watch.Added:
-					log.Printf("%s added: %v", resourceType, name)
-					g.AddNode(event.Object)
-					updateRelationships(g, unstructuredObj, resourceType, name, namespace)
-					if resourceType == "Pod" {
-						cacheMutex.Lock()
-						podCache[fmt.Sprintf("%s/%s", namespace, name)] = unstructuredObj
-						cacheMutex.Unlock()
-					} else if resourceType == "Service" {
-						cacheMutex.Lock()
-						serviceCache[fmt.Sprintf("%s/%s", namespace, name)] = unstructuredObj
-						cacheMutex.Unlock()
-					}
-				case watch.Modified:
-					log.Printf("%s modified: %v", resourceType, name)
-					g.UpdateNode(event.Object)
-					updateRelationships(g, unstructuredObj, resourceType, name, namespace)
-					if resourceType == "Pod" {
-						cacheMutex.Lock()
-						podCache[fmt.Sprintf("%s/%s", namespace, name)] = unstructuredObj
-						cacheMutex.Unlock()
-					} else if resourceType == "Service" {
-						cacheMutex.Lock()
-						serviceCache[fmt.Sprintf("%s/%s", namespace, name)] = unstructuredObj
-						cacheMutex.Unlock()
-					}
-				case watch.Deleted:
-					log.Printf("%s deleted: %v", resourceType, name)
-					g.RemoveNode(event.Object)
-					// Remove all relationships involving this resource
-					removeResourceRelationships(g, graph.EntityKey{Name: name, Namespace: namespace, Type: resourceType})
-					if resourceType == "Pod" {
-						cacheMutex.Lock()
-						delete(podCache, fmt.Sprintf("%s/%s", namespace, name))
-						cacheMutex.Unlock()
-					} else if resourceType == "Service" {
-						cacheMutex.Lock()
-						delete(serviceCache, fmt.Sprintf("%s/%s", namespace, name))
-						cacheMutex.Unlock()
-					}
-				}
-			}
-		}
+// isRecreated reports whether an update event replaces an object with a new
+// incarnation under the same name
+func isRecreated(oldObj, newObj interface{}) bool {
+	oldMeta, err := meta.Accessor(oldObj)
+	if err != nil {
+		return false
 	}
+	newMeta, err := meta.Accessor(newObj)
+	if err != nil {
+		return false
+	}
+	return oldMeta.GetUID() != newMeta.GetUID()
 }
 
-func updateRelationships(g *graph.Graph, obj map[string]interface{}, resourceType, name, namespace string) {
-	switch resourceType {
-	case "Pod":
-		// Pod -> Node relationship
-		if nodeName, ok := obj["spec"].(map[string]interface{})["nodeName"].(string); ok && nodeName != "" {
-			g.AddRelationship(
-				graph.EntityKey{Name: name, Namespace: namespace, Type: "Pod"},
-				graph.EntityKey{Name: nodeName, Type: "Node"},
-				"runs_on",
-				nil,
-			)
-		}
-
-		// Pod -> ReplicaSet relationship
-		if ownerRefs, ok := obj["metadata"].(map[string]interface{})["ownerReferences"].([]interface{}); ok {
-			for _, ownerRef := range ownerRefs {
-				owner := ownerRef.(map[string]interface{})
-				if owner["kind"].(string) == "ReplicaSet" {
-					g.AddRelationship(
-						graph.EntityKey{Name: name, Namespace: namespace, Type: "Pod"},
-						graph.EntityKey{Name: owner["name"].(string), Namespace: namespace, Type: "ReplicaSet"},
-						"owned_by",
-						nil,
-					)
-				}
-			}
-		}
+// applyObject runs obj through the extractor for its kind and adds the
+// resulting node and relationships to the graph. Relationships extracted
+// from an earlier version of obj that it no longer has are removed.
+func applyObject(g *graph.Graph, resourceType string, obj interface{}) (*extractor.Result, error) {
+	result, err := extractor.Extract(resourceType, obj)
+	if err != nil {
+		return nil, err
+	}
 
-	case "ReplicaSet":
-		// ReplicaSet -> Deployment relationship
-		if ownerRefs, ok := obj["metadata"].(map[string]interface{})["ownerReferences"].([]interface{}); ok {
-			for _, ownerRef := range ownerRefs {
-				owner := ownerRef.(map[string]interface{})
-				if owner["kind"].(string) == "Deployment" {
-					g.AddRelationship(
-						graph.EntityKey{Name: name, Namespace: namespace, Type: "ReplicaSet"},
-						graph.EntityKey{Name: owner["name"].(string), Namespace: namespace, Type: "Deployment"},
-						"owned_by",
-						nil,
-					)
-				}
-			}
-		}
+	origin := extractedOrigin(result.Node.Key)
+	g.AddNode(result.Node)
+	g.SyncNodes(origin, result.Related)
+	g.SyncRelationships(origin, result.Relationships)
 
-	case "Service":
-		// Service -> Pod relationships based on selector
-		if selector, ok := obj["spec"].(map[string]interface{})["selector"].(map[string]interface{}); ok {
-			// Get all current pods and update relationships with this service
-			cacheMutex.RLock()
-			for _, pod := range podCache {
-				podName := pod["metadata"].(map[string]interface{})["name"].(string)
-				podNamespace := pod["metadata"].(map[string]interface{})["namespace"].(string)
-
-				// Get pod labels with nil check
-				podLabels := make(map[string]interface{})
-				if labelsInterface, ok := pod["metadata"].(map[string]interface{})["labels"]; ok && labelsInterface != nil {
-					podLabels = labelsInterface.(map[string]interface{})
-				}
+	return result, nil
+}
 
-				// Check if pod labels match service selector
-				matches := true
-				for key, value := range selector {
-					if podLabels[key] != value {
-						matches = false
-						break
-					}
-				}
+// removeObject removes obj from the graph, along with all relationships involving it
+func removeObject(g *graph.Graph, resourceType string, obj interface{}) (*extractor.Result, error) {
+	result, err := extractor.Extract(resourceType, obj)
+	if err != nil {
+		return nil, err
+	}
 
-				if matches {
-					g.AddRelationship(
-						graph.EntityKey{Name: name, Namespace: namespace, Type: "Service"},
-						graph.EntityKey{Name: podName, Namespace: podNamespace, Type: "Pod"},
-						"targets",
-						nil,
-					)
-				} else {
-					// Remove relationship if it exists but no longer matches
-					g.RemoveRelationship(
-						graph.EntityKey{Name: name, Namespace: namespace, Type: "Service"},
-						graph.EntityKey{Name: podName, Namespace: podNamespace, Type: "Pod"},
-						"targets",
-					)
-				}
-			}
-			cacheMutex.RUnlock()
-		}
+	origin := extractedOrigin(result.Node.Key)
+	g.SyncRelationships(origin, nil)
+	g.SyncNodes(origin, nil)
+	g.RemoveNode(result.Node.Key)
 
-	case "Deployment":
-		// Deployment -> ConfigMap relationships
-		if volumes, ok := obj["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["volumes"].([]interface{}); ok {
-			for _, volume := range volumes {
-				vol := volume.(map[string]interface{})
-				if configMap, ok := vol["configMap"]; ok {
-					configMapName := configMap.(map[string]interface{})["name"].(string)
-					g.AddRelationship(
-						graph.EntityKey{Name: name, Namespace: namespace, Type: "Deployment"},
-						graph.EntityKey{Name: configMapName, Namespace: namespace, Type: "ConfigMap"},
-						"uses",
-						nil,
-					)
-				}
-			}
-		}
-	}
+	return result, nil
 }
 
-func removeResourceRelationships(g *graph.Graph, key graph.EntityKey) {
-	// Remove all relationships where this resource is either the source or target
-	for _, rel := range g.Relationships {
-		if (rel.Source.Name == key.Name && rel.Source.Namespace == key.Namespace && rel.Source.Type == key.Type) ||
-			(rel.Target.Name == key.Name && rel.Target.Namespace == key.Namespace && rel.Target.Type == key.Type) {
-			g.RemoveRelationship(rel.Source, rel.Target, rel.RelationshipType)
-		}
-	}
+// extractedOrigin identifies the relationships extracted from an object
+func extractedOrigin(key graph.EntityKey) string {
+	return "extractor:" + key.String()
 }
 
 func emitGraph(ctx context.Context, g *graph.Graph) {
@@ -0,0 +1,589 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/AdityaaMK/kubernetes-scraper/extractor"
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	"github.com/AdityaaMK/kubernetes-scraper/k8sclient"
+	"github.com/AdityaaMK/kubernetes-scraper/selector"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// allPorts is the ports property of reachability relationships that allow every port
+const allPorts = "*"
+
+// anyPeer is the target of reachability relationships from rules without
+// peers, which allow every pod and every address
+var anyPeer = graph.EntityKey{Name: "*", Type: "AnyPeer"}
+
+// networkPolicyRelationships maintains the relationships derived from
+// NetworkPolicies: applies_to relationships from each policy to the pods it
+// selects, and allowed_ingress_from and allowed_egress_to relationships from
+// each policy to the peers its rules allow.
+//
+// The pods a policy applies to form a PodGroup, and each peer of its rules
+// is a single node as well: a PodGroup with includes relationships to the
+// pods it selects, an IPBlock or AnyPeer. The reachability relationships of
+// a policy are repeated from its own PodGroup, so that groups of pods are
+// related directly. The relationships therefore grow with the number of
+// pods and peers rather than with their product, and PodGroups with the
+// same selectors are shared between policies.
+//
+// A policy's peers depend on the labels of pods and namespaces anywhere in
+// the cluster, so policies are recomputed as a whole from a work queue,
+// which coalesces bursts of pod and namespace events.
+type networkPolicyRelationships struct {
+	g         *graph.Graph
+	informers *k8sclient.Informers
+	appliesTo *selectorRelationships
+	queue     workqueue.Interface
+
+	// The peers of each policy that can select pods outside its own namespace
+	mu             sync.Mutex
+	crossNamespace map[string][]selector.PeerMatcher
+
+	// Peer nodes referenced by each policy, and the number of policies
+	// referencing each of them. Only the run goroutine touches these.
+	peers    map[string]map[graph.EntityKey]struct{}
+	peerRefs map[graph.EntityKey]int
+}
+
+// reachabilityKey identifies a reachability relationship of a policy while it is evaluated
+type reachabilityKey struct {
+	target           graph.EntityKey
+	relationshipType string
+	qualifier        string
+}
+
+// reachability accumulates the ports a policy allows to or from one peer
+type reachability struct {
+	rel      graph.GraphRelationship
+	allPorts bool
+	ports    map[string]struct{}
+}
+
+// policyPeers holds the peer nodes of a policy and the matchers of its PodGroups
+type policyPeers struct {
+	nodes  map[graph.EntityKey]graph.GraphNode
+	groups map[graph.EntityKey]selector.PeerMatcher
+}
+
+func newNetworkPolicyRelationships(g *graph.Graph, informers *k8sclient.Informers) *networkPolicyRelationships {
+	return &networkPolicyRelationships{
+		g:              g,
+		informers:      informers,
+		appliesTo:      newSelectorRelationships(g, informers, "NetworkPolicy", "applies_to", networkPolicySelector),
+		queue:          workqueue.New(),
+		crossNamespace: make(map[string][]selector.PeerMatcher),
+		peers:          make(map[string]map[graph.EntityKey]struct{}),
+		peerRefs:       make(map[graph.EntityKey]int),
+	}
+}
+
+// register adds the event handlers that queue policies for recomputing
+func (n *networkPolicyRelationships) register() error {
+	if err := n.appliesTo.register(); err != nil {
+		return err
+	}
+
+	enqueuePolicy := func(obj interface{}) {
+		key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+		if err != nil {
+			log.Printf("Error queueing NetworkPolicy: %v", err)
+			return
+		}
+		n.queue.Add(key)
+	}
+	err := n.informers.AddEventHandler("NetworkPolicy", cache.ResourceEventHandlerFuncs{
+		AddFunc:    enqueuePolicy,
+		UpdateFunc: func(_, newObj interface{}) { enqueuePolicy(newObj) },
+		DeleteFunc: enqueuePolicy,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Deletes are queued too: a policy evaluated before a pod was deleted
+	// may be applied after the pod's node has been removed
+	enqueuePod := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if pod, ok := obj.(*corev1.Pod); ok {
+			n.enqueueNamespace(pod.Namespace)
+		}
+	}
+	err = n.informers.AddEventHandler("Pod", cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueuePod,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, ok := oldObj.(*corev1.Pod)
+			newPod, ok2 := newObj.(*corev1.Pod)
+			if ok && ok2 && labels.Equals(oldPod.Labels, newPod.Labels) {
+				return
+			}
+			enqueuePod(newObj)
+		},
+		DeleteFunc: enqueuePod,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Namespace labels only matter to peers with a namespaceSelector, and
+	// only to those matching the namespace before or after the change
+	enqueueNamespaceLabels := func(objs ...interface{}) {
+		for _, obj := range objs {
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if namespace, ok := obj.(*corev1.Namespace); ok {
+				n.enqueueCrossNamespace(namespace.Name, namespace.Labels)
+			}
+		}
+	}
+	return n.informers.AddEventHandler("Namespace", cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { enqueueNamespaceLabels(obj) },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNamespace, ok := oldObj.(*corev1.Namespace)
+			newNamespace, ok2 := newObj.(*corev1.Namespace)
+			if ok && ok2 && labels.Equals(oldNamespace.Labels, newNamespace.Labels) {
+				return
+			}
+			enqueueNamespaceLabels(oldObj, newObj)
+		},
+		DeleteFunc: func(obj interface{}) { enqueueNamespaceLabels(obj) },
+	})
+}
+
+// resync queues every policy once the caches have synced
+func (n *networkPolicyRelationships) resync() {
+	n.appliesTo.resync()
+	for _, key := range n.informers.Indexer("NetworkPolicy").ListKeys() {
+		n.queue.Add(key)
+	}
+}
+
+// run recomputes queued policies until ctx is cancelled
+func (n *networkPolicyRelationships) run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		n.queue.ShutDown()
+	}()
+
+	for {
+		item, shutdown := n.queue.Get()
+		if shutdown {
+			return
+		}
+		n.syncPolicy(item.(string))
+		n.queue.Done(item)
+	}
+}
+
+// enqueueNamespace queues the policies that can select pods in namespace:
+// those in the namespace and those whose namespaceSelectors match it. If the
+// namespace is no longer cached its labels are unknown, so every policy
+// with a namespaceSelector is queued.
+func (n *networkPolicyRelationships) enqueueNamespace(namespace string) {
+	keys, err := n.informers.Indexer("NetworkPolicy").IndexKeys(cache.NamespaceIndex, namespace)
+	if err != nil {
+		log.Printf("Error listing NetworkPolicies in namespace %s: %v", namespace, err)
+	}
+	for _, key := range keys {
+		n.queue.Add(key)
+	}
+
+	obj, exists, err := n.informers.Indexer("Namespace").GetByKey(namespace)
+	if err != nil {
+		log.Printf("Error getting Namespace %s: %v", namespace, err)
+	}
+	if ns, ok := obj.(*corev1.Namespace); exists && ok {
+		n.enqueueCrossNamespace(namespace, ns.Labels)
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for key := range n.crossNamespace {
+		n.queue.Add(key)
+	}
+}
+
+// enqueueCrossNamespace queues the policies with a namespaceSelector that
+// matches a namespace with the given labels
+func (n *networkPolicyRelationships) enqueueCrossNamespace(namespace string, namespaceLabels map[string]string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for key, matchers := range n.crossNamespace {
+		for _, matcher := range matchers {
+			if matcher.MatchesNamespace(namespace, namespaceLabels) {
+				n.queue.Add(key)
+				break
+			}
+		}
+	}
+}
+
+// syncPolicy recomputes the reachability relationships of the policy with
+// the given namespace/name key, or releases them if it no longer exists
+func (n *networkPolicyRelationships) syncPolicy(key string) {
+	origin := "networkpolicy:" + key
+
+	obj, exists, err := n.informers.Indexer("NetworkPolicy").GetByKey(key)
+	if err != nil {
+		log.Printf("Error getting NetworkPolicy %s: %v", key, err)
+		return
+	}
+	policy, ok := obj.(*networkingv1.NetworkPolicy)
+	if !exists || !ok {
+		n.setCrossNamespace(key, nil)
+		n.g.SyncRelationships(origin, nil)
+		n.setPeers(key, nil)
+		return
+	}
+
+	rels, peers, err := n.policyRelationships(policy)
+	if err != nil {
+		log.Printf("Error evaluating NetworkPolicy %s: %v", key, err)
+		return
+	}
+
+	var crossNamespace []selector.PeerMatcher
+	for _, matcher := range peers.groups {
+		if matcher.CrossNamespace() {
+			crossNamespace = append(crossNamespace, matcher)
+		}
+	}
+
+	// Peer nodes are added before and removed after the relationships, so
+	// that the relationships are never dangling
+	n.setCrossNamespace(key, crossNamespace)
+	n.addPeers(key, peers.nodes)
+	for group, matcher := range peers.groups {
+		if err := n.syncGroup(group, matcher); err != nil {
+			log.Printf("Error evaluating %s of NetworkPolicy %s: %v", group, key, err)
+		}
+	}
+	n.g.SyncRelationships(origin, rels)
+
+	keys := make(map[graph.EntityKey]struct{}, len(peers.nodes))
+	for peer := range peers.nodes {
+		keys[peer] = struct{}{}
+	}
+	n.setPeers(key, keys)
+}
+
+// syncGroup recomputes the includes relationships of a PodGroup to the
+// cached pods its peer selects
+func (n *networkPolicyRelationships) syncGroup(group graph.EntityKey, matcher selector.PeerMatcher) error {
+	pods, err := n.peerPods(matcher)
+	if err != nil {
+		return err
+	}
+
+	rels := make([]graph.GraphRelationship, 0, len(pods))
+	for _, pod := range pods {
+		rels = append(rels, graph.GraphRelationship{
+			Source:           group,
+			Target:           pod,
+			RelationshipType: "includes",
+		})
+	}
+	n.g.SyncRelationships(groupOrigin(group), rels)
+	return nil
+}
+
+// groupOrigin identifies the includes relationships of a PodGroup
+func groupOrigin(group graph.EntityKey) string {
+	return "podgroup:" + group.String()
+}
+
+// addPeers adds the peer nodes a policy references that are not in the graph yet
+func (n *networkPolicyRelationships) addPeers(policy string, peers map[graph.EntityKey]graph.GraphNode) {
+	for key, node := range peers {
+		if _, ok := n.peers[policy][key]; ok {
+			continue
+		}
+		if n.peerRefs[key] == 0 {
+			n.g.AddNode(node)
+		}
+		n.peerRefs[key]++
+	}
+}
+
+// setPeers records the peer nodes a policy references once its relationships
+// are synced, removing the nodes that no policy references any more along
+// with the includes relationships of PodGroups
+func (n *networkPolicyRelationships) setPeers(policy string, peers map[graph.EntityKey]struct{}) {
+	for key := range n.peers[policy] {
+		if _, ok := peers[key]; ok {
+			continue
+		}
+		n.peerRefs[key]--
+		if n.peerRefs[key] == 0 {
+			delete(n.peerRefs, key)
+			if key.Type == "PodGroup" {
+				n.g.SyncRelationships(groupOrigin(key), nil)
+			}
+			n.g.RemoveNode(key)
+		}
+	}
+
+	if len(peers) == 0 {
+		delete(n.peers, policy)
+	} else {
+		n.peers[policy] = peers
+	}
+}
+
+// setCrossNamespace records the peers of a policy that can select pods in other namespaces
+func (n *networkPolicyRelationships) setCrossNamespace(key string, matchers []selector.PeerMatcher) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if len(matchers) > 0 {
+		n.crossNamespace[key] = matchers
+	} else {
+		delete(n.crossNamespace, key)
+	}
+}
+
+// policyRelationships evaluates the rules of a policy into relationships
+// from the policy, and from the PodGroup of the pods it applies to, to its
+// peers, and returns the PodGroup and the peer nodes they point at
+func (n *networkPolicyRelationships) policyRelationships(policy *networkingv1.NetworkPolicy) ([]graph.GraphRelationship, policyPeers, error) {
+	policyKey := graph.EntityKey{Name: policy.Name, Namespace: policy.Namespace, Type: "NetworkPolicy"}
+
+	edges := make(map[reachabilityKey]*reachability)
+	peers := policyPeers{
+		nodes:  make(map[graph.EntityKey]graph.GraphNode),
+		groups: make(map[graph.EntityKey]selector.PeerMatcher),
+	}
+	ingress, egress := selector.NetworkPolicyTypes(policy)
+
+	if ingress {
+		for _, rule := range policy.Spec.Ingress {
+			if err := addRule(edges, peers, policyKey, "allowed_ingress_from", rule.From, rule.Ports); err != nil {
+				return nil, policyPeers{}, err
+			}
+		}
+	}
+	if egress {
+		for _, rule := range policy.Spec.Egress {
+			if err := addRule(edges, peers, policyKey, "allowed_egress_to", rule.To, rule.Ports); err != nil {
+				return nil, policyPeers{}, err
+			}
+		}
+	}
+
+	if len(edges) == 0 {
+		return nil, peers, nil
+	}
+
+	// The same reachability from the group of pods the policy applies to,
+	// qualified by the policy since policies can share the group
+	matcher, err := selector.ForNetworkPolicy(policy)
+	if err != nil {
+		return nil, policyPeers{}, err
+	}
+	selected := peerGroupNode(selector.PeerMatcher{Namespace: policy.Namespace, Pods: matcher.Selector})
+	peers.nodes[selected.Key] = selected
+	peers.groups[selected.Key] = selector.PeerMatcher{Namespace: policy.Namespace, Pods: matcher.Selector}
+
+	rels := make([]graph.GraphRelationship, 0, 2*len(edges))
+	for _, edge := range edges {
+		ports := allPorts
+		if !edge.allPorts {
+			ports = extractor.JoinSet(edge.ports)
+		}
+		edge.rel.Properties["ports"] = ports
+		rels = append(rels, edge.rel)
+
+		groupRel := edge.rel
+		groupRel.Source = selected.Key
+		groupRel.Qualifier = strings.TrimSpace(policy.Name + " " + edge.rel.Qualifier)
+		groupRel.Properties = map[string]string{"policy": policy.Name}
+		for k, v := range edge.rel.Properties {
+			groupRel.Properties[k] = v
+		}
+		rels = append(rels, groupRel)
+	}
+	return rels, peers, nil
+}
+
+// addRule adds the relationships of one ingress or egress rule from the
+// policy to each of the rule's peers, and records the peer nodes in peers.
+// A rule without peers allows every pod and every address, which is
+// represented by the anyPeer target.
+func addRule(edges map[reachabilityKey]*reachability, peers policyPeers, policyKey graph.EntityKey,
+	relationshipType string, rulePeers []networkingv1.NetworkPolicyPeer, ports []networkingv1.NetworkPolicyPort) error {
+	type peerTarget struct {
+		key    graph.EntityKey
+		except []string
+	}
+	var targets []peerTarget
+
+	if len(rulePeers) == 0 {
+		targets = append(targets, peerTarget{key: anyPeer})
+		peers.nodes[anyPeer] = graph.GraphNode{Key: anyPeer, Properties: map[string]interface{}{}, Revision: 1}
+	}
+
+	for _, peer := range rulePeers {
+		if peer.IPBlock != nil {
+			key := graph.EntityKey{Name: peer.IPBlock.CIDR, Type: "IPBlock"}
+			except := append([]string(nil), peer.IPBlock.Except...)
+			sort.Strings(except)
+			targets = append(targets, peerTarget{key: key, except: except})
+			peers.nodes[key] = graph.GraphNode{Key: key, Properties: map[string]interface{}{}, Revision: 1}
+			continue
+		}
+
+		matcher, ok, err := selector.ForNetworkPolicyPeer(policyKey.Namespace, peer)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		node := peerGroupNode(matcher)
+		targets = append(targets, peerTarget{key: node.Key})
+		peers.nodes[node.Key] = node
+		peers.groups[node.Key] = matcher
+	}
+
+	rulePorts := networkPolicyPorts(ports)
+	for _, target := range targets {
+		// Parallel relationships to an IP block are told apart by its exceptions
+		qualifier := ""
+		if len(target.except) > 0 {
+			qualifier = "except " + strings.Join(target.except, ",")
+		}
+
+		id := reachabilityKey{target: target.key, relationshipType: relationshipType, qualifier: qualifier}
+		edge, ok := edges[id]
+		if !ok {
+			edge = &reachability{
+				rel: graph.GraphRelationship{
+					Source:           policyKey,
+					Target:           target.key,
+					RelationshipType: relationshipType,
+					Qualifier:        qualifier,
+					Properties:       map[string]string{},
+				},
+				ports: make(map[string]struct{}),
+			}
+			if len(target.except) > 0 {
+				edge.rel.Properties["except"] = strings.Join(target.except, ",")
+			}
+			edges[id] = edge
+		}
+
+		if rulePorts == nil {
+			edge.allPorts = true
+		}
+		for _, port := range rulePorts {
+			edge.ports[port] = struct{}{}
+		}
+	}
+
+	return nil
+}
+
+// peerGroupNode returns the PodGroup node standing for the pods a peer
+// selects. It is named after the peer's selectors, so peers selecting the
+// same pods share it. Peers without a namespaceSelector select pods in the
+// policy's namespace, and their group is in that namespace too.
+func peerGroupNode(matcher selector.PeerMatcher) graph.GraphNode {
+	key := graph.EntityKey{Name: "pods:" + selectorString(matcher.Pods), Namespace: matcher.Namespace, Type: "PodGroup"}
+	properties := map[string]interface{}{
+		"podSelector": selectorString(matcher.Pods),
+	}
+	if matcher.CrossNamespace() {
+		key.Namespace = ""
+		key.Name = "namespaces:" + selectorString(matcher.Namespaces) + " " + key.Name
+		properties["namespaceSelector"] = selectorString(matcher.Namespaces)
+	}
+	return graph.GraphNode{Key: key, Properties: properties, Revision: 1}
+}
+
+// selectorString formats a selector, or * for one that matches everything
+func selectorString(s labels.Selector) string {
+	if s.Empty() {
+		return "*"
+	}
+	return s.String()
+}
+
+// pods returns the keys of the cached pods in namespace for which match returns true
+func (n *networkPolicyRelationships) pods(match func(*corev1.Pod) bool, namespace string) ([]graph.EntityKey, error) {
+	objs, err := n.informers.Indexer("Pod").ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []graph.EntityKey
+	for _, obj := range objs {
+		if pod, ok := obj.(*corev1.Pod); ok && match(pod) {
+			keys = append(keys, graph.EntityKey{Name: pod.Name, Namespace: pod.Namespace, Type: "Pod"})
+		}
+	}
+	return keys, nil
+}
+
+// peerPods returns the keys of the cached pods a peer selects
+func (n *networkPolicyRelationships) peerPods(matcher selector.PeerMatcher) ([]graph.EntityKey, error) {
+	if !matcher.CrossNamespace() {
+		return n.pods(func(pod *corev1.Pod) bool { return matcher.Pods.Matches(labels.Set(pod.Labels)) }, matcher.Namespace)
+	}
+
+	var keys []graph.EntityKey
+	for _, obj := range n.informers.Indexer("Namespace").List() {
+		namespace, ok := obj.(*corev1.Namespace)
+		if !ok || !matcher.MatchesNamespace(namespace.Name, namespace.Labels) {
+			continue
+		}
+		pods, err := n.pods(func(pod *corev1.Pod) bool { return matcher.Pods.Matches(labels.Set(pod.Labels)) }, namespace.Name)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, pods...)
+	}
+	return keys, nil
+}
+
+// networkPolicyPorts formats the ports of a rule as protocol/port,
+// protocol/port-endPort or protocol alone for every port of a protocol. It
+// returns nil if the rule allows every port.
+func networkPolicyPorts(ports []networkingv1.NetworkPolicyPort) []string {
+	if len(ports) == 0 {
+		return nil
+	}
+
+	formatted := make([]string, 0, len(ports))
+	for _, port := range ports {
+		protocol := corev1.ProtocolTCP
+		if port.Protocol != nil {
+			protocol = *port.Protocol
+		}
+		value := string(protocol)
+		if port.Port != nil {
+			value += "/" + port.Port.String()
+			if port.EndPort != nil {
+				value += fmt.Sprintf("-%d", *port.EndPort)
+			}
+		}
+		formatted = append(formatted, value)
+	}
+	return formatted
+}
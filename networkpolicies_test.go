@@ -0,0 +1,343 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func testNetworkPolicy(name string, spec networkingv1.NetworkPolicySpec) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}, Spec: spec}
+}
+
+func policyPort(protocol corev1.Protocol, port int32, endPort *int32) networkingv1.NetworkPolicyPort {
+	p := intstr.FromInt32(port)
+	return networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &p, EndPort: endPort}
+}
+
+// queued drains the keys queued so far, sorted
+func queued(q workqueue.Interface) []string {
+	var keys []string
+	for q.Len() > 0 {
+		item, _ := q.Get()
+		q.Done(item)
+		keys = append(keys, item.(string))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestPolicyRelationships(t *testing.T) {
+	appDB := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}
+	teamA := &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+	endPort := int32(5440)
+
+	anyPeerKey := graph.EntityKey{Name: "*", Type: "AnyPeer"}
+	ipBlock := graph.EntityKey{Name: "10.0.0.0/8", Type: "IPBlock"}
+	dbGroup := graph.EntityKey{Name: "pods:app=db", Namespace: "default", Type: "PodGroup"}
+	everyPod := graph.EntityKey{Name: "pods:*", Namespace: "default", Type: "PodGroup"}
+	teamAGroup := graph.EntityKey{Name: "namespaces:team=a pods:*", Type: "PodGroup"}
+
+	// edge is the part of a reachability relationship the tests compare
+	type edge struct {
+		relationshipType string
+		target           graph.EntityKey
+		qualifier        string
+		ports            string
+		except           string
+	}
+
+	// selected is the PodGroup of the pods the policy applies to, and nodes
+	// the peer nodes besides it
+	tests := []struct {
+		name     string
+		spec     networkingv1.NetworkPolicySpec
+		edges    []edge
+		selected graph.EntityKey
+		nodes    []graph.EntityKey
+	}{
+		{
+			name: "rule without peers or ports",
+			spec: networkingv1.NetworkPolicySpec{
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{}},
+			},
+			edges:    []edge{{"allowed_ingress_from", anyPeerKey, "", "*", ""}},
+			selected: everyPod,
+			nodes:    []graph.EntityKey{anyPeerKey},
+		},
+		{
+			name: "ipBlock with and without exceptions",
+			spec: networkingv1.NetworkPolicySpec{
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						From:  []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.2.0.0/16", "10.1.0.0/16"}}}},
+						Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolTCP, 80, nil), policyPort(corev1.ProtocolUDP, 53, nil)},
+					},
+					{
+						From:  []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8"}}},
+						Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolTCP, 443, nil)},
+					},
+				},
+			},
+			edges: []edge{
+				{"allowed_ingress_from", ipBlock, "", "TCP/443", ""},
+				{"allowed_ingress_from", ipBlock, "except 10.1.0.0/16,10.2.0.0/16", "TCP/80,UDP/53", "10.1.0.0/16,10.2.0.0/16"},
+			},
+			selected: everyPod,
+			nodes:    []graph.EntityKey{ipBlock},
+		},
+		{
+			name: "port range and protocol without port",
+			spec: networkingv1.NetworkPolicySpec{
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress: []networkingv1.NetworkPolicyEgressRule{{
+					To: []networkingv1.NetworkPolicyPeer{{PodSelector: appDB}},
+					Ports: []networkingv1.NetworkPolicyPort{
+						policyPort(corev1.ProtocolTCP, 5432, &endPort),
+						{Protocol: func() *corev1.Protocol { p := corev1.ProtocolSCTP; return &p }()},
+					},
+				}},
+			},
+			edges:    []edge{{"allowed_egress_to", dbGroup, "", "SCTP,TCP/5432-5440", ""}},
+			selected: everyPod,
+			nodes:    []graph.EntityKey{dbGroup},
+		},
+		{
+			name: "rules to the same peer merge their ports",
+			spec: networkingv1.NetworkPolicySpec{
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+				Egress: []networkingv1.NetworkPolicyEgressRule{
+					{To: []networkingv1.NetworkPolicyPeer{{PodSelector: appDB}}, Ports: []networkingv1.NetworkPolicyPort{policyPort(corev1.ProtocolTCP, 5432, nil)}},
+					{To: []networkingv1.NetworkPolicyPeer{{PodSelector: appDB}}},
+				},
+			},
+			edges:    []edge{{"allowed_egress_to", dbGroup, "", "*", ""}},
+			selected: everyPod,
+			nodes:    []graph.EntityKey{dbGroup},
+		},
+		{
+			name: "namespace selector",
+			spec: networkingv1.NetworkPolicySpec{
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: teamA}},
+				}},
+			},
+			edges:    []edge{{"allowed_ingress_from", teamAGroup, "", "*", ""}},
+			selected: everyPod,
+			nodes:    []graph.EntityKey{teamAGroup},
+		},
+		{
+			name: "policy selecting the pods of its peer",
+			spec: networkingv1.NetworkPolicySpec{
+				PodSelector: *appDB,
+				Ingress: []networkingv1.NetworkPolicyIngressRule{{
+					From: []networkingv1.NetworkPolicyPeer{{PodSelector: appDB}},
+				}},
+			},
+			edges:    []edge{{"allowed_ingress_from", dbGroup, "", "*", ""}},
+			selected: dbGroup,
+		},
+		{
+			name: "rules of types not in policyTypes are ignored",
+			spec: networkingv1.NetworkPolicySpec{
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Egress:      []networkingv1.NetworkPolicyEgressRule{{}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newNetworkPolicyRelationships(graph.NewGraph(), newTestInformers(t))
+			policy := testNetworkPolicy("policy", tt.spec)
+			rels, peers, err := n.policyRelationships(policy)
+			if err != nil {
+				t.Fatalf("policyRelationships() error = %v", err)
+			}
+
+			// The policy's relationships are repeated from its PodGroup, qualified by the policy
+			policyKey := graph.EntityKey{Name: "policy", Namespace: "default", Type: "NetworkPolicy"}
+			var edges, groupEdges, wantGroupEdges []edge
+			for _, rel := range rels {
+				e := edge{rel.RelationshipType, rel.Target, rel.Qualifier, rel.Properties["ports"], rel.Properties["except"]}
+				switch rel.Source {
+				case policyKey:
+					edges = append(edges, e)
+				case tt.selected:
+					if rel.Properties["policy"] != "policy" {
+						t.Errorf("policy property = %q, want %q", rel.Properties["policy"], "policy")
+					}
+					groupEdges = append(groupEdges, e)
+				default:
+					t.Errorf("relationship source = %v, want %v or %v", rel.Source, policyKey, tt.selected)
+				}
+			}
+			for _, e := range tt.edges {
+				e.qualifier = strings.TrimSpace("policy " + e.qualifier)
+				wantGroupEdges = append(wantGroupEdges, e)
+			}
+			sort.Slice(edges, func(i, j int) bool { return edges[i].qualifier < edges[j].qualifier })
+			sort.Slice(groupEdges, func(i, j int) bool { return groupEdges[i].qualifier < groupEdges[j].qualifier })
+			if !reflect.DeepEqual(edges, tt.edges) {
+				t.Errorf("relationships = %v, want %v", edges, tt.edges)
+			}
+			if !reflect.DeepEqual(groupEdges, wantGroupEdges) {
+				t.Errorf("PodGroup relationships = %v, want %v", groupEdges, wantGroupEdges)
+			}
+
+			wantNodes := make(map[graph.EntityKey]struct{})
+			for _, key := range tt.nodes {
+				wantNodes[key] = struct{}{}
+			}
+			if tt.edges != nil {
+				wantNodes[tt.selected] = struct{}{}
+			}
+			nodes := make(map[graph.EntityKey]struct{})
+			for key := range peers.nodes {
+				nodes[key] = struct{}{}
+			}
+			if !reflect.DeepEqual(nodes, wantNodes) {
+				t.Errorf("peer nodes = %v, want %v", nodes, wantNodes)
+			}
+		})
+	}
+}
+
+func TestSharedPeerGroups(t *testing.T) {
+	informers := newTestInformers(t)
+	g := graph.NewGraph()
+	n := newNetworkPolicyRelationships(g, informers)
+
+	web := graph.EntityKey{Name: "web-0", Namespace: "default", Type: "Pod"}
+	group := graph.EntityKey{Name: "pods:app=web", Namespace: "default", Type: "PodGroup"}
+	selected := graph.EntityKey{Name: "pods:*", Namespace: "default", Type: "PodGroup"}
+	fromWeb := networkingv1.NetworkPolicySpec{
+		Ingress: []networkingv1.NetworkPolicyIngressRule{{
+			From: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}}},
+		}},
+	}
+	first := testNetworkPolicy("first", fromWeb)
+	second := testNetworkPolicy("second", fromWeb)
+	firstKey := graph.EntityKey{Name: "first", Namespace: "default", Type: "NetworkPolicy"}
+	secondKey := graph.EntityKey{Name: "second", Namespace: "default", Type: "NetworkPolicy"}
+
+	addToCache(t, informers, "Pod", testPod("web-0", "pod-1", map[string]string{"app": "web"}))
+	addToCache(t, informers, "NetworkPolicy", first, second)
+	n.syncPolicy("default/first")
+	n.syncPolicy("default/second")
+
+	if _, ok := g.Node(group); !ok {
+		t.Fatalf("PodGroup %s missing", group)
+	}
+	if !hasRelationship(g, group, web, "includes") {
+		t.Fatalf("missing includes relationship from %s to %s", group, web)
+	}
+	for _, policy := range []graph.EntityKey{firstKey, secondKey} {
+		if !hasRelationship(g, policy, group, "allowed_ingress_from") {
+			t.Fatalf("missing allowed_ingress_from relationship from %s", policy)
+		}
+	}
+
+	// Both policies apply to every pod, so their group relates to the peer
+	// group once for each of them
+	groupQualifiers := func() []string {
+		var qualifiers []string
+		for _, rel := range g.Outgoing(selected) {
+			if rel.Target == group && rel.RelationshipType == "allowed_ingress_from" {
+				qualifiers = append(qualifiers, rel.Qualifier)
+			}
+		}
+		sort.Strings(qualifiers)
+		return qualifiers
+	}
+	if got, want := groupQualifiers(), []string{"first", "second"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("allowed_ingress_from relationships from %s = %v, want %v", selected, got, want)
+	}
+	if !hasRelationship(g, selected, web, "includes") {
+		t.Fatalf("missing includes relationship from %s to %s", selected, web)
+	}
+
+	// The group outlives the first policy, as the second still references it
+	deleteFromCache(t, informers, "NetworkPolicy", first)
+	n.syncPolicy("default/first")
+	if got := g.Outgoing(firstKey); len(got) != 0 {
+		t.Errorf("deleted policy relationships = %v, want none", got)
+	}
+	if _, ok := g.Node(group); !ok {
+		t.Fatalf("PodGroup %s removed while still referenced", group)
+	}
+	if !hasRelationship(g, group, web, "includes") || !hasRelationship(g, secondKey, group, "allowed_ingress_from") {
+		t.Fatalf("relationships of the remaining policy removed")
+	}
+	if got, want := groupQualifiers(), []string{"second"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("allowed_ingress_from relationships from %s after deleting first = %v, want %v", selected, got, want)
+	}
+
+	deleteFromCache(t, informers, "NetworkPolicy", second)
+	n.syncPolicy("default/second")
+	for _, key := range []graph.EntityKey{group, selected} {
+		if _, ok := g.Node(key); ok {
+			t.Errorf("PodGroup %s kept after its last policy was deleted", key)
+		}
+	}
+	if got := g.Incoming(web); len(got) != 0 {
+		t.Errorf("includes relationships = %v, want none", got)
+	}
+}
+
+func TestEnqueueCrossNamespace(t *testing.T) {
+	informers := newTestInformers(t)
+	n := newNetworkPolicyRelationships(graph.NewGraph(), informers)
+
+	sameNamespace := testNetworkPolicy("same-namespace", networkingv1.NetworkPolicySpec{
+		Ingress: []networkingv1.NetworkPolicyIngressRule{{
+			From: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}},
+		}},
+	})
+	teamA := testNetworkPolicy("team-a", networkingv1.NetworkPolicySpec{
+		Ingress: []networkingv1.NetworkPolicyIngressRule{{
+			From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}}},
+		}},
+	})
+	addToCache(t, informers, "NetworkPolicy", sameNamespace, teamA)
+	addToCache(t, informers, "Namespace", &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "a", Labels: map[string]string{"team": "a"}}})
+	n.syncPolicy("default/same-namespace")
+	n.syncPolicy("default/team-a")
+
+	tests := []struct {
+		name    string
+		enqueue func()
+		want    []string
+	}{
+		{"matching namespace labels", func() { n.enqueueCrossNamespace("b", map[string]string{"team": "a"}) }, []string{"default/team-a"}},
+		{"other namespace labels", func() { n.enqueueCrossNamespace("b", map[string]string{"team": "b"}) }, nil},
+		{"pod in the policies' namespace", func() { n.enqueueNamespace("default") }, []string{"default/same-namespace", "default/team-a"}},
+		{"pod in a matching namespace", func() { n.enqueueNamespace("a") }, []string{"default/team-a"}},
+		{"pod in an uncached namespace", func() { n.enqueueNamespace("gone") }, []string{"default/team-a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.enqueue()
+			if got := queued(n.queue); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("queued %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// A deleted policy is no longer requeued by namespace changes
+	deleteFromCache(t, informers, "NetworkPolicy", teamA)
+	n.syncPolicy("default/team-a")
+	n.enqueueCrossNamespace("a", map[string]string{"team": "a"})
+	if got := queued(n.queue); got != nil {
+		t.Errorf("queued %v after delete, want none", got)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/AdityaaMK/kubernetes-scraper/extractor"
+	"github.com/AdityaaMK/kubernetes-scraper/k8sclient"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// resourcesConfig is the file passed to -resources, listing resources with
+// no built-in support to scrape through the dynamic client
+type resourcesConfig struct {
+	Resources []resourceConfig `json:"resources"`
+}
+
+// resourceConfig selects a resource by group, optional version and plural
+// name, and declares the node properties of its objects
+type resourceConfig struct {
+	Group      string                            `json:"group"`
+	Version    string                            `json:"version"`
+	Resource   string                            `json:"resource"`
+	Properties map[string]extractor.PropertySpec `json:"properties"`
+}
+
+// loadDynamicResources reads the resources listed in the file at path,
+// resolves each through API discovery and registers an extractor for its
+// kind, qualified by its group. Resources whose kind is already scraped,
+// such as a built-in resource or one listed twice, are skipped.
+func loadDynamicResources(client *k8sclient.K8sClient, path string) ([]k8sclient.DynamicResource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config resourcesConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+
+	resources := make([]k8sclient.DynamicResource, 0, len(config.Resources))
+	for _, rc := range config.Resources {
+		resource, err := client.Discover(schema.GroupVersionResource{Group: rc.Group, Version: rc.Version, Resource: rc.Resource})
+		if err != nil {
+			return nil, err
+		}
+		resource.Type = extractor.TypeName(resource.Resource.Group, resource.Kind)
+		if _, ok := extractor.For(resource.Type); ok {
+			log.Printf("Skipping resource %s: %s is already scraped", resource.Resource.String(), resource.Type)
+			continue
+		}
+		if err := extractor.RegisterUnstructured(resource.Type, rc.Properties); err != nil {
+			return nil, fmt.Errorf("error registering %s: %v", resource.Resource.String(), err)
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+// loadPropertySchema reads the file passed to -properties, which maps kinds
+// to the node properties to add to their objects, and adds them to the
+// kinds' property schemas
+func loadPropertySchema(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var schema map[string]map[string]extractor.PropertySpec
+	if err := yaml.UnmarshalStrict(data, &schema); err != nil {
+		return fmt.Errorf("error parsing %s: %v", path, err)
+	}
+
+	for kind, properties := range schema {
+		if err := extractor.AddPropertySchema(kind, properties); err != nil {
+			return err
+		}
+	}
+	return nil
+}
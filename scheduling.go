@@ -0,0 +1,203 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/AdityaaMK/kubernetes-scraper/extractor"
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	"github.com/AdityaaMK/kubernetes-scraper/k8sclient"
+	"github.com/AdityaaMK/kubernetes-scraper/selector"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// schedulingRelationships maintains schedulable_on relationships from each
+// pod to the nodes the scheduler could place it on, given the pod's
+// nodeSelector, required node affinity, tolerations and topology spread
+// constraints and the nodes' labels and taints.
+//
+// A pod is evaluated against every node, and a change to a node's labels or
+// taints re-evaluates every pod, so the relationships cost one evaluation
+// per pod and node. Node events are ignored until the first resync, so the
+// initial list of nodes does not re-evaluate every pod once per node. Pods
+// that have finished are not schedulable anywhere.
+type schedulingRelationships struct {
+	g         *graph.Graph
+	informers *k8sclient.Informers
+	synced    bool
+	mu        sync.Mutex
+}
+
+// newSchedulingRelationships creates schedulable_on relationships from pods to nodes
+func newSchedulingRelationships(g *graph.Graph, informers *k8sclient.Informers) *schedulingRelationships {
+	return &schedulingRelationships{
+		g:         g,
+		informers: informers,
+	}
+}
+
+// register adds the event handlers for pods and nodes
+func (s *schedulingRelationships) register() error {
+	updatePod := func(obj interface{}) {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			s.updatePod(pod)
+		}
+	}
+	deletePod := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if pod, ok := obj.(*corev1.Pod); ok {
+			s.deletePod(graph.EntityKey{Name: pod.Name, Namespace: pod.Namespace, Type: "Pod"})
+		}
+	}
+
+	err := s.informers.AddEventHandler("Pod", cache.ResourceEventHandlerFuncs{
+		AddFunc: updatePod,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, ok := oldObj.(*corev1.Pod)
+			newPod, ok2 := newObj.(*corev1.Pod)
+			if ok && ok2 && !schedulingChanged(oldPod, newPod) {
+				return
+			}
+			updatePod(newObj)
+		},
+		DeleteFunc: deletePod,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.informers.AddEventHandler("Node", cache.ResourceEventHandlerFuncs{
+		AddFunc: func(interface{}) { s.updateNodes() },
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldNode, ok := oldObj.(*corev1.Node)
+			newNode, ok2 := newObj.(*corev1.Node)
+			if ok && ok2 && labels.Equals(oldNode.Labels, newNode.Labels) &&
+				equality.Semantic.DeepEqual(oldNode.Spec.Taints, newNode.Spec.Taints) {
+				return
+			}
+			s.updateNodes()
+		},
+		DeleteFunc: func(interface{}) { s.updateNodes() },
+	})
+}
+
+// schedulingChanged reports whether a pod update can change the nodes it is
+// schedulable on. Node affinity and the nodeSelector are immutable once a
+// pod is scheduled, but tolerations can be added and the pod can finish.
+func schedulingChanged(oldPod, newPod *corev1.Pod) bool {
+	return podFinished(oldPod) != podFinished(newPod) ||
+		!equality.Semantic.DeepEqual(oldPod.Spec.Tolerations, newPod.Spec.Tolerations) ||
+		!equality.Semantic.DeepEqual(oldPod.Spec.NodeSelector, newPod.Spec.NodeSelector) ||
+		!equality.Semantic.DeepEqual(oldPod.Spec.Affinity, newPod.Spec.Affinity)
+}
+
+// podFinished reports whether all of a pod's containers have terminated for good
+func podFinished(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+}
+
+// resync evaluates every pod once against the synced node cache, and
+// starts handling node events
+func (s *schedulingRelationships) resync() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.synced = true
+	s.syncPods()
+}
+
+// updateNodes re-evaluates every pod after a node was added, removed or had
+// its labels or taints changed
+func (s *schedulingRelationships) updateNodes() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.synced {
+		s.syncPods()
+	}
+}
+
+// syncPods evaluates every pod against the nodes in the cache.
+// The caller must hold mu.
+func (s *schedulingRelationships) syncPods() {
+	nodes := s.informers.Indexer("Node").List()
+	for _, obj := range s.informers.Indexer("Pod").List() {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			s.syncPod(pod, nodes)
+		}
+	}
+}
+
+// updatePod re-evaluates a pod against the nodes in the cache
+func (s *schedulingRelationships) updatePod(pod *corev1.Pod) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.syncPod(pod, s.informers.Indexer("Node").List())
+}
+
+// deletePod removes the relationships from a deleted pod
+func (s *schedulingRelationships) deletePod(podKey graph.EntityKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.g.SyncRelationships(schedulingOrigin(podKey), nil)
+}
+
+// syncPod replaces the relationships from pod with one to each of nodes it
+// can be scheduled on. Each relationship records the kinds of constraints
+// the pod has, the node's taints it tolerates and the node's topology
+// domains for its spread constraints. The caller must hold mu.
+func (s *schedulingRelationships) syncPod(pod *corev1.Pod, nodes []interface{}) {
+	podKey := graph.EntityKey{Name: pod.Name, Namespace: pod.Namespace, Type: "Pod"}
+	if podFinished(pod) {
+		s.g.SyncRelationships(schedulingOrigin(podKey), nil)
+		return
+	}
+
+	matcher, err := selector.ForPodNodes(pod)
+	if err != nil {
+		log.Printf("Invalid node affinity on Pod %s/%s: %v", pod.Namespace, pod.Name, err)
+		s.g.SyncRelationships(schedulingOrigin(podKey), nil)
+		return
+	}
+	constraints := strings.Join(matcher.Constraints(), ",")
+
+	var rels []graph.GraphRelationship
+	for _, obj := range nodes {
+		node, ok := obj.(*corev1.Node)
+		if !ok {
+			continue
+		}
+		taints, ok := matcher.Matches(node)
+		if !ok {
+			continue
+		}
+		tolerated := make(map[string]struct{}, len(taints))
+		for _, taint := range taints {
+			tolerated[taint.ToString()] = struct{}{}
+		}
+		rels = append(rels, graph.GraphRelationship{
+			Source:           podKey,
+			Target:           graph.EntityKey{Name: node.Name, Type: "Node"},
+			RelationshipType: "schedulable_on",
+			Properties: map[string]string{
+				"constraints": constraints,
+				"tolerated":   extractor.JoinSet(tolerated),
+				"topology":    strings.Join(matcher.Topology(node), ","),
+			},
+		})
+	}
+	s.g.SyncRelationships(schedulingOrigin(podKey), rels)
+}
+
+// schedulingOrigin identifies the schedulable_on relationships of a pod
+func schedulingOrigin(podKey graph.EntityKey) string {
+	return "scheduling:" + podKey.String()
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testNode(name string, labels map[string]string, unschedulable bool, taints ...corev1.Taint) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Spec:       corev1.NodeSpec{Unschedulable: unschedulable, Taints: taints},
+	}
+}
+
+// schedulableOn returns the tolerated property of the schedulable_on relationships of a pod by node name
+func schedulableOn(t *testing.T, g *graph.Graph, pod graph.EntityKey) map[string]string {
+	t.Helper()
+	got := make(map[string]string)
+	for _, rel := range g.Outgoing(pod) {
+		if rel.RelationshipType != "schedulable_on" {
+			t.Errorf("unexpected %s relationship to %s", rel.RelationshipType, rel.Target)
+			continue
+		}
+		got[rel.Target.Name] = rel.Properties["tolerated"]
+	}
+	return got
+}
+
+func TestSchedulingRelationships(t *testing.T) {
+	gpuTaint := corev1.Taint{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}
+	cordonTaint := corev1.Taint{Key: corev1.TaintNodeUnschedulable, Effect: corev1.TaintEffectNoSchedule}
+	nodes := []interface{}{
+		testNode("ssd", map[string]string{"disktype": "ssd"}, false),
+		testNode("hdd", map[string]string{"disktype": "hdd"}, false),
+		testNode("gpu", map[string]string{"disktype": "ssd"}, false, gpuTaint, corev1.Taint{Key: "spot", Effect: corev1.TaintEffectPreferNoSchedule}),
+		testNode("cordoned", map[string]string{"disktype": "ssd"}, true),
+		testNode("drained", map[string]string{"disktype": "hdd"}, true, cordonTaint),
+	}
+	tolerateGPU := corev1.Toleration{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "gpu", Effect: corev1.TaintEffectNoSchedule}
+	tolerateCordon := corev1.Toleration{Key: corev1.TaintNodeUnschedulable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule}
+	requiredAffinity := func(key string, values ...string) *corev1.Affinity {
+		return &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+				MatchExpressions: []corev1.NodeSelectorRequirement{{Key: key, Operator: corev1.NodeSelectorOpIn, Values: values}},
+			}}},
+		}}
+	}
+
+	tests := []struct {
+		name  string
+		spec  corev1.PodSpec
+		phase corev1.PodPhase
+		want  map[string]string
+	}{
+		{
+			name: "untainted and uncordoned nodes",
+			want: map[string]string{"ssd": "", "hdd": ""},
+		},
+		{
+			name: "nodeSelector",
+			spec: corev1.PodSpec{NodeSelector: map[string]string{"disktype": "ssd"}},
+			want: map[string]string{"ssd": ""},
+		},
+		{
+			name: "required node affinity",
+			spec: corev1.PodSpec{Affinity: requiredAffinity("disktype", "hdd")},
+			want: map[string]string{"hdd": ""},
+		},
+		{
+			name: "tolerated NoSchedule taint",
+			spec: corev1.PodSpec{Tolerations: []corev1.Toleration{tolerateGPU}},
+			want: map[string]string{"ssd": "", "hdd": "", "gpu": "dedicated=gpu:NoSchedule"},
+		},
+		{
+			name: "tolerated cordon",
+			spec: corev1.PodSpec{Tolerations: []corev1.Toleration{tolerateCordon}},
+			want: map[string]string{
+				"ssd":      "",
+				"hdd":      "",
+				"cordoned": "node.kubernetes.io/unschedulable:NoSchedule",
+				"drained":  "node.kubernetes.io/unschedulable:NoSchedule",
+			},
+		},
+		{
+			name: "every constraint",
+			spec: corev1.PodSpec{
+				NodeSelector: map[string]string{"disktype": "ssd"},
+				Tolerations:  []corev1.Toleration{tolerateGPU, tolerateCordon},
+			},
+			want: map[string]string{"ssd": "", "gpu": "dedicated=gpu:NoSchedule", "cordoned": "node.kubernetes.io/unschedulable:NoSchedule"},
+		},
+		{
+			name:  "finished pod",
+			phase: corev1.PodSucceeded,
+			want:  map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			informers := newTestInformers(t)
+			g := graph.NewGraph()
+			s := newSchedulingRelationships(g, informers)
+			addToCache(t, informers, "Node", nodes...)
+
+			pod := testPod("web-0", "pod-1", nil)
+			pod.Spec = tt.spec
+			pod.Status.Phase = tt.phase
+			podKey := graph.EntityKey{Name: "web-0", Namespace: "default", Type: "Pod"}
+
+			s.updatePod(pod)
+			if got := schedulableOn(t, g, podKey); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("schedulable_on = %v, want %v", got, tt.want)
+			}
+
+			s.deletePod(podKey)
+			if got := g.Outgoing(podKey); len(got) != 0 {
+				t.Errorf("schedulable_on after delete = %v, want none", got)
+			}
+		})
+	}
+}
+
+func TestSchedulingNodeUpdates(t *testing.T) {
+	informers := newTestInformers(t)
+	g := graph.NewGraph()
+	s := newSchedulingRelationships(g, informers)
+	podKey := graph.EntityKey{Name: "web-0", Namespace: "default", Type: "Pod"}
+	addToCache(t, informers, "Pod", testPod("web-0", "pod-1", nil))
+	addToCache(t, informers, "Node", testNode("node-1", nil, false))
+
+	// Node events before the first resync are ignored
+	s.updateNodes()
+	if got := g.Outgoing(podKey); len(got) != 0 {
+		t.Fatalf("schedulable_on before resync = %v, want none", got)
+	}
+
+	s.resync()
+	if got, want := schedulableOn(t, g, podKey), map[string]string{"node-1": ""}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("schedulable_on after resync = %v, want %v", got, want)
+	}
+
+	// Cordoning the node re-evaluates the pod
+	addToCache(t, informers, "Node", testNode("node-1", nil, true), testNode("node-2", nil, false))
+	s.updateNodes()
+	if got, want := schedulableOn(t, g, podKey), map[string]string{"node-2": ""}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("schedulable_on after cordon = %v, want %v", got, want)
+	}
+}
+
+func TestSchedulingChanged(t *testing.T) {
+	pod := func(mutate func(*corev1.Pod)) *corev1.Pod {
+		p := testPod("web-0", "pod-1", map[string]string{"app": "web"})
+		p.Spec.NodeSelector = map[string]string{"disktype": "ssd"}
+		p.Spec.Tolerations = []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}}
+		p.Status.Phase = corev1.PodRunning
+		if mutate != nil {
+			mutate(p)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(*corev1.Pod)
+		want   bool
+	}{
+		{"unchanged", nil, false},
+		{"labels", func(p *corev1.Pod) { p.Labels["version"] = "2" }, false},
+		{"resource version", func(p *corev1.Pod) { p.ResourceVersion = "pod-2" }, false},
+		{"container status", func(p *corev1.Pod) {
+			p.Status.ContainerStatuses = []corev1.ContainerStatus{{Name: "web", Ready: true}}
+		}, false},
+		{"node name", func(p *corev1.Pod) { p.Spec.NodeName = "node-1" }, false},
+		{"finished", func(p *corev1.Pod) { p.Status.Phase = corev1.PodFailed }, true},
+		{"toleration added", func(p *corev1.Pod) {
+			p.Spec.Tolerations = append(p.Spec.Tolerations, corev1.Toleration{Key: "spot", Operator: corev1.TolerationOpExists})
+		}, true},
+		{"nodeSelector", func(p *corev1.Pod) { p.Spec.NodeSelector["disktype"] = "hdd" }, true},
+		{"affinity", func(p *corev1.Pod) { p.Spec.Affinity = &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{}} }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schedulingChanged(pod(nil), pod(tt.mutate)); got != tt.want {
+				t.Errorf("schedulingChanged() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package selector
+
+import (
+	"sync"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// labelPair is a single label key and value
+type labelPair struct {
+	key   string
+	value string
+}
+
+// Index is a reverse index from pod labels to the objects whose selectors
+// could match them. Each selector is indexed under the values of one of its
+// equality requirements, since a pod can only match if it carries one of
+// those labels; selectors without any equality requirement are checked
+// against every pod in their namespace.
+type Index struct {
+	mu        sync.RWMutex
+	matchers  map[graph.EntityKey]Matcher
+	indexedAs map[graph.EntityKey][]labelPair
+	byLabel   map[string]map[labelPair]map[graph.EntityKey]struct{}
+	unindexed map[string]map[graph.EntityKey]struct{}
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		matchers:  make(map[graph.EntityKey]Matcher),
+		indexedAs: make(map[graph.EntityKey][]labelPair),
+		byLabel:   make(map[string]map[labelPair]map[graph.EntityKey]struct{}),
+		unindexed: make(map[string]map[graph.EntityKey]struct{}),
+	}
+}
+
+// Set adds or replaces the matcher of the selecting object key
+func (i *Index) Set(key graph.EntityKey, m Matcher) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.delete(key)
+	i.matchers[key] = m
+
+	requirements, selectable := m.Selector.Requirements()
+	if !selectable {
+		// The selector matches nothing, so it never needs evaluating
+		return
+	}
+
+	if pairs := indexPairs(requirements); pairs != nil {
+		i.indexedAs[key] = pairs
+		for _, pair := range pairs {
+			byPair, ok := i.byLabel[m.Namespace]
+			if !ok {
+				byPair = make(map[labelPair]map[graph.EntityKey]struct{})
+				i.byLabel[m.Namespace] = byPair
+			}
+			keys, ok := byPair[pair]
+			if !ok {
+				keys = make(map[graph.EntityKey]struct{})
+				byPair[pair] = keys
+			}
+			keys[key] = struct{}{}
+		}
+		return
+	}
+
+	keys, ok := i.unindexed[m.Namespace]
+	if !ok {
+		keys = make(map[graph.EntityKey]struct{})
+		i.unindexed[m.Namespace] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+// Delete removes the selecting object key from the index
+func (i *Index) Delete(key graph.EntityKey) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.delete(key)
+}
+
+// Matching returns the selecting objects whose selectors match a pod in
+// namespace with the given labels
+func (i *Index) Matching(namespace string, podLabels map[string]string) []graph.EntityKey {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	candidates := make(map[graph.EntityKey]struct{})
+	if byPair, ok := i.byLabel[namespace]; ok {
+		for key, value := range podLabels {
+			for candidate := range byPair[labelPair{key: key, value: value}] {
+				candidates[candidate] = struct{}{}
+			}
+		}
+	}
+	for candidate := range i.unindexed[namespace] {
+		candidates[candidate] = struct{}{}
+	}
+
+	var matching []graph.EntityKey
+	for candidate := range candidates {
+		if i.matchers[candidate].Matches(namespace, podLabels) {
+			matching = append(matching, candidate)
+		}
+	}
+	return matching
+}
+
+// delete removes key from the index.
+// The caller must hold the write lock.
+func (i *Index) delete(key graph.EntityKey) {
+	m, ok := i.matchers[key]
+	if !ok {
+		return
+	}
+	delete(i.matchers, key)
+
+	for _, pair := range i.indexedAs[key] {
+		keys := i.byLabel[m.Namespace][pair]
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(i.byLabel[m.Namespace], pair)
+		}
+	}
+	if len(i.byLabel[m.Namespace]) == 0 {
+		delete(i.byLabel, m.Namespace)
+	}
+	delete(i.indexedAs, key)
+
+	if keys, ok := i.unindexed[m.Namespace]; ok {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(i.unindexed, m.Namespace)
+		}
+	}
+}
+
+// indexPairs returns the label pairs to index a selector under, taken from
+// its first equality or set-inclusion requirement, or nil if it has none
+func indexPairs(requirements labels.Requirements) []labelPair {
+	for _, requirement := range requirements {
+		switch requirement.Operator() {
+		case selection.Equals, selection.DoubleEquals, selection.In:
+			var pairs []labelPair
+			for _, value := range requirement.Values().List() {
+				pairs = append(pairs, labelPair{key: requirement.Key(), value: value})
+			}
+			return pairs
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package selector
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// nodeNameField is the only field node selector terms can match on
+const nodeNameField = "metadata.name"
+
+// unschedulableTaint is the taint of cordoned nodes
+var unschedulableTaint = corev1.Taint{Key: corev1.TaintNodeUnschedulable, Effect: corev1.TaintEffectNoSchedule}
+
+// nodeSelectorTerm is a required node affinity term; a node matches if it
+// meets both its label and field requirements
+type nodeSelectorTerm struct {
+	labels labels.Selector
+	fields []corev1.NodeSelectorRequirement
+}
+
+// NodeMatcher selects the nodes a pod can be scheduled on, following the
+// scheduler's hard constraints: the pod's nodeSelector, its required node
+// affinity and its tolerations of the nodes' NoSchedule and NoExecute
+// taints, cordoned nodes counting as tainted. Preferred affinity and
+// PreferNoSchedule taints only rank nodes, so they are ignored. Topology
+// spread constraints that must be satisfied rule out nodes without their
+// topology key.
+type NodeMatcher struct {
+	nodeSelector labels.Selector
+	// affinity is nil without required node affinity, and its terms are ORed
+	affinity     []nodeSelectorTerm
+	tolerations  []corev1.Toleration
+	topologyKeys []string
+	// requiredTopologyKeys are the keys of DoNotSchedule spread constraints
+	requiredTopologyKeys []string
+}
+
+// ForPodNodes returns the matcher for the nodes pod can be scheduled on
+func ForPodNodes(pod *corev1.Pod) (NodeMatcher, error) {
+	m := NodeMatcher{
+		nodeSelector: labels.SelectorFromSet(pod.Spec.NodeSelector),
+		tolerations:  pod.Spec.Tolerations,
+	}
+	for _, constraint := range pod.Spec.TopologySpreadConstraints {
+		m.topologyKeys = append(m.topologyKeys, constraint.TopologyKey)
+		if constraint.WhenUnsatisfiable == corev1.DoNotSchedule {
+			m.requiredTopologyKeys = append(m.requiredTopologyKeys, constraint.TopologyKey)
+		}
+	}
+
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return m, nil
+	}
+	m.affinity = []nodeSelectorTerm{}
+	for _, term := range affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		// A term without requirements matches no nodes
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			continue
+		}
+		labelSelector, err := nodeSelectorRequirements(term.MatchExpressions)
+		if err != nil {
+			return NodeMatcher{}, err
+		}
+		for _, requirement := range term.MatchFields {
+			if requirement.Key != nodeNameField {
+				return NodeMatcher{}, fmt.Errorf("%q is not a valid node field selector key", requirement.Key)
+			}
+		}
+		m.affinity = append(m.affinity, nodeSelectorTerm{labels: labelSelector, fields: term.MatchFields})
+	}
+	return m, nil
+}
+
+// Matches reports whether the pod can be scheduled on node, along with the
+// node's taints it has to tolerate to get there
+func (m NodeMatcher) Matches(node *corev1.Node) ([]corev1.Taint, bool) {
+	if !m.nodeSelector.Matches(labels.Set(node.Labels)) {
+		return nil, false
+	}
+
+	if m.affinity != nil {
+		matched := false
+		for _, term := range m.affinity {
+			if term.labels.Matches(labels.Set(node.Labels)) && matchesFields(term.fields, node.Name) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, false
+		}
+	}
+
+	for _, key := range m.requiredTopologyKeys {
+		if _, ok := node.Labels[key]; !ok {
+			return nil, false
+		}
+	}
+
+	var tolerated []corev1.Taint
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if !m.tolerates(taint) {
+			return nil, false
+		}
+		tolerated = append(tolerated, *taint)
+	}
+
+	// A cordoned node only takes pods that tolerate the taint the node
+	// lifecycle controller marks it with, whether or not it has been added yet
+	if node.Spec.Unschedulable && !hasTaint(node.Spec.Taints, unschedulableTaint) {
+		if !m.tolerates(&unschedulableTaint) {
+			return nil, false
+		}
+		tolerated = append(tolerated, unschedulableTaint)
+	}
+	return tolerated, true
+}
+
+// hasTaint reports whether taints include one with the key and effect of taint
+func hasTaint(taints []corev1.Taint, taint corev1.Taint) bool {
+	for i := range taints {
+		if taints[i].MatchTaint(&taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// Constraints names the kinds of hard constraints the pod has on the nodes
+// it can be scheduled on: nodeSelector, nodeAffinity, tolerations and
+// topologySpreadConstraints
+func (m NodeMatcher) Constraints() []string {
+	var constraints []string
+	if !m.nodeSelector.Empty() {
+		constraints = append(constraints, "nodeSelector")
+	}
+	if m.affinity != nil {
+		constraints = append(constraints, "nodeAffinity")
+	}
+	if len(m.tolerations) > 0 {
+		constraints = append(constraints, "tolerations")
+	}
+	if len(m.topologyKeys) > 0 {
+		constraints = append(constraints, "topologySpreadConstraints")
+	}
+	return constraints
+}
+
+// Topology returns the topology domains node is in for each of the pod's
+// topology spread constraints, formatted as key=value
+func (m NodeMatcher) Topology(node *corev1.Node) []string {
+	var domains []string
+	for _, key := range m.topologyKeys {
+		if value, ok := node.Labels[key]; ok {
+			domains = append(domains, key+"="+value)
+		}
+	}
+	return domains
+}
+
+// tolerates reports whether any of the pod's tolerations tolerates taint
+func (m NodeMatcher) tolerates(taint *corev1.Taint) bool {
+	for i := range m.tolerations {
+		if m.tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFields reports whether a node's name meets every field requirement.
+// Node names can be longer than label values, so they are compared directly.
+func matchesFields(requirements []corev1.NodeSelectorRequirement, name string) bool {
+	for _, requirement := range requirements {
+		in := false
+		for _, value := range requirement.Values {
+			if value == name {
+				in = true
+				break
+			}
+		}
+		switch requirement.Operator {
+		case corev1.NodeSelectorOpIn:
+			if !in {
+				return false
+			}
+		case corev1.NodeSelectorOpNotIn:
+			if in {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// nodeSelectorRequirements converts node selector requirements, which
+// unlike label selectors support the Gt and Lt operators, into a selector
+func nodeSelectorRequirements(requirements []corev1.NodeSelectorRequirement) (labels.Selector, error) {
+	s := labels.NewSelector()
+	for _, requirement := range requirements {
+		var op selection.Operator
+		switch requirement.Operator {
+		case corev1.NodeSelectorOpIn:
+			op = selection.In
+		case corev1.NodeSelectorOpNotIn:
+			op = selection.NotIn
+		case corev1.NodeSelectorOpExists:
+			op = selection.Exists
+		case corev1.NodeSelectorOpDoesNotExist:
+			op = selection.DoesNotExist
+		case corev1.NodeSelectorOpGt:
+			op = selection.GreaterThan
+		case corev1.NodeSelectorOpLt:
+			op = selection.LessThan
+		default:
+			return nil, fmt.Errorf("%q is not a valid node selector operator", requirement.Operator)
+		}
+		r, err := labels.NewRequirement(requirement.Key, op, requirement.Values)
+		if err != nil {
+			return nil, err
+		}
+		s = s.Add(*r)
+	}
+	return s, nil
+}
@@ -0,0 +1,153 @@
+package selector
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Matcher selects pods by label within a single namespace, following the
+// selector semantics of the kind of object it was built from.
+type Matcher struct {
+	Namespace string
+	Selector  labels.Selector
+}
+
+// Matches reports whether a pod in namespace with the given labels is selected
+func (m Matcher) Matches(namespace string, podLabels map[string]string) bool {
+	return namespace == m.Namespace && m.Selector.Matches(labels.Set(podLabels))
+}
+
+// MatchesPod reports whether pod is selected
+func (m Matcher) MatchesPod(pod *corev1.Pod) bool {
+	return m.Matches(pod.Namespace, pod.Labels)
+}
+
+// ForService returns the matcher for a Service. A Service with a nil or
+// empty selector does not select any pods; its endpoints are managed
+// externally.
+func ForService(service *corev1.Service) Matcher {
+	if len(service.Spec.Selector) == 0 {
+		return Matcher{Namespace: service.Namespace, Selector: labels.Nothing()}
+	}
+	return Matcher{Namespace: service.Namespace, Selector: labels.SelectorFromSet(service.Spec.Selector)}
+}
+
+// ForNetworkPolicy returns the matcher for the pods a NetworkPolicy applies
+// to. An empty podSelector selects every pod in the namespace.
+func ForNetworkPolicy(policy *networkingv1.NetworkPolicy) (Matcher, error) {
+	return fromLabelSelector(policy.Namespace, &policy.Spec.PodSelector, true)
+}
+
+// NetworkPolicyTypes reports whether a NetworkPolicy restricts ingress and
+// egress of the pods it applies to. Without explicit policyTypes a policy
+// always restricts ingress, and restricts egress only if it has egress rules.
+func NetworkPolicyTypes(policy *networkingv1.NetworkPolicy) (ingress, egress bool) {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		return true, len(policy.Spec.Egress) > 0
+	}
+	for _, policyType := range policy.Spec.PolicyTypes {
+		switch policyType {
+		case networkingv1.PolicyTypeIngress:
+			ingress = true
+		case networkingv1.PolicyTypeEgress:
+			egress = true
+		}
+	}
+	return ingress, egress
+}
+
+// PeerMatcher selects the pods of a NetworkPolicy peer, which unlike other
+// selectors may span namespaces.
+type PeerMatcher struct {
+	// Namespace is the policy's namespace, the only one matched when
+	// Namespaces is nil
+	Namespace  string
+	Namespaces labels.Selector
+	Pods       labels.Selector
+}
+
+// CrossNamespace reports whether the peer can select pods outside the policy's namespace
+func (m PeerMatcher) CrossNamespace() bool {
+	return m.Namespaces != nil
+}
+
+// MatchesNamespace reports whether pods in a namespace with the given labels can be selected
+func (m PeerMatcher) MatchesNamespace(namespace string, namespaceLabels map[string]string) bool {
+	if m.Namespaces == nil {
+		return namespace == m.Namespace
+	}
+	return m.Namespaces.Matches(labels.Set(namespaceLabels))
+}
+
+// Matches reports whether a pod with the given labels, in a namespace with
+// the given labels, is selected
+func (m PeerMatcher) Matches(namespace string, namespaceLabels, podLabels map[string]string) bool {
+	return m.MatchesNamespace(namespace, namespaceLabels) && m.Pods.Matches(labels.Set(podLabels))
+}
+
+// ForNetworkPolicyPeer returns the matcher for the pods of a NetworkPolicy
+// peer in namespace. A peer with only a podSelector selects pods in the
+// policy's namespace, a peer with only a namespaceSelector selects every pod
+// in the matching namespaces, and empty selectors match everything. It
+// returns false for ipBlock peers, which select no pods.
+func ForNetworkPolicyPeer(namespace string, peer networkingv1.NetworkPolicyPeer) (PeerMatcher, bool, error) {
+	if peer.PodSelector == nil && peer.NamespaceSelector == nil {
+		return PeerMatcher{}, false, nil
+	}
+
+	m := PeerMatcher{Namespace: namespace, Pods: labels.Everything()}
+	if peer.PodSelector != nil {
+		s, err := metav1.LabelSelectorAsSelector(peer.PodSelector)
+		if err != nil {
+			return PeerMatcher{}, false, err
+		}
+		m.Pods = s
+	}
+	if peer.NamespaceSelector != nil {
+		s, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+		if err != nil {
+			return PeerMatcher{}, false, err
+		}
+		m.Namespaces = s
+	}
+	return m, true, nil
+}
+
+// ForPodDisruptionBudget returns the matcher for a PodDisruptionBudget. In
+// policy/v1 a nil selector selects no pods and an empty selector selects
+// every pod in the namespace.
+func ForPodDisruptionBudget(pdb *policyv1.PodDisruptionBudget) (Matcher, error) {
+	return fromLabelSelector(pdb.Namespace, pdb.Spec.Selector, true)
+}
+
+// ForDeployment returns the matcher for the pods a Deployment manages.
+// apps/v1 requires a non-empty selector, so a nil or empty one selects no pods.
+func ForDeployment(deployment *appsv1.Deployment) (Matcher, error) {
+	return fromLabelSelector(deployment.Namespace, deployment.Spec.Selector, false)
+}
+
+// fromLabelSelector builds a matcher from a LabelSelector, including its
+// matchExpressions. A nil selector never matches; emptyMatchesAll decides
+// whether a non-nil selector without requirements matches everything or
+// nothing.
+func fromLabelSelector(namespace string, ls *metav1.LabelSelector, emptyMatchesAll bool) (Matcher, error) {
+	if ls == nil {
+		return Matcher{Namespace: namespace, Selector: labels.Nothing()}, nil
+	}
+	if len(ls.MatchLabels) == 0 && len(ls.MatchExpressions) == 0 {
+		if emptyMatchesAll {
+			return Matcher{Namespace: namespace, Selector: labels.Everything()}, nil
+		}
+		return Matcher{Namespace: namespace, Selector: labels.Nothing()}, nil
+	}
+
+	s, err := metav1.LabelSelectorAsSelector(ls)
+	if err != nil {
+		return Matcher{}, err
+	}
+	return Matcher{Namespace: namespace, Selector: s}, nil
+}
@@ -0,0 +1,274 @@
+package selector
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestMatcherSemantics(t *testing.T) {
+	objectMeta := metav1.ObjectMeta{Name: "selector", Namespace: "default"}
+	appWeb := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	tierIn := &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+		{Key: "tier", Operator: metav1.LabelSelectorOpIn, Values: []string{"frontend", "backend"}},
+	}}
+	empty := &metav1.LabelSelector{}
+
+	service := func(selector map[string]string) func() (Matcher, error) {
+		return func() (Matcher, error) {
+			return ForService(&corev1.Service{ObjectMeta: objectMeta, Spec: corev1.ServiceSpec{Selector: selector}}), nil
+		}
+	}
+	networkPolicy := func(selector metav1.LabelSelector) func() (Matcher, error) {
+		return func() (Matcher, error) {
+			return ForNetworkPolicy(&networkingv1.NetworkPolicy{ObjectMeta: objectMeta, Spec: networkingv1.NetworkPolicySpec{PodSelector: selector}})
+		}
+	}
+	pdb := func(selector *metav1.LabelSelector) func() (Matcher, error) {
+		return func() (Matcher, error) {
+			return ForPodDisruptionBudget(&policyv1.PodDisruptionBudget{ObjectMeta: objectMeta, Spec: policyv1.PodDisruptionBudgetSpec{Selector: selector}})
+		}
+	}
+	deployment := func(selector *metav1.LabelSelector) func() (Matcher, error) {
+		return func() (Matcher, error) {
+			return ForDeployment(&appsv1.Deployment{ObjectMeta: objectMeta, Spec: appsv1.DeploymentSpec{Selector: selector}})
+		}
+	}
+
+	tests := []struct {
+		name      string
+		matcher   func() (Matcher, error)
+		namespace string
+		labels    map[string]string
+		want      bool
+	}{
+		{"service selector matches", service(map[string]string{"app": "web"}), "default", map[string]string{"app": "web", "tier": "frontend"}, true},
+		{"service selector mismatch", service(map[string]string{"app": "web"}), "default", map[string]string{"app": "db"}, false},
+		{"service other namespace", service(map[string]string{"app": "web"}), "other", map[string]string{"app": "web"}, false},
+		{"service nil selector", service(nil), "default", map[string]string{"app": "web"}, false},
+		{"service empty selector", service(map[string]string{}), "default", map[string]string{}, false},
+
+		{"network policy matchExpressions", networkPolicy(*tierIn), "default", map[string]string{"tier": "backend"}, true},
+		{"network policy matchExpressions mismatch", networkPolicy(*tierIn), "default", map[string]string{"tier": "cache"}, false},
+		{"network policy empty selector", networkPolicy(*empty), "default", map[string]string{}, true},
+		{"network policy empty selector other namespace", networkPolicy(*empty), "other", map[string]string{}, false},
+
+		{"pdb selector matches", pdb(appWeb), "default", map[string]string{"app": "web"}, true},
+		{"pdb nil selector", pdb(nil), "default", map[string]string{"app": "web"}, false},
+		{"pdb empty selector", pdb(empty), "default", map[string]string{"app": "web"}, true},
+
+		{"deployment selector matches", deployment(appWeb), "default", map[string]string{"app": "web"}, true},
+		{"deployment selector other namespace", deployment(appWeb), "other", map[string]string{"app": "web"}, false},
+		{"deployment matchExpressions", deployment(tierIn), "default", map[string]string{"tier": "frontend"}, true},
+		{"deployment nil selector", deployment(nil), "default", map[string]string{"app": "web"}, false},
+		{"deployment empty selector", deployment(empty), "default", map[string]string{"app": "web"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := tt.matcher()
+			if err != nil {
+				t.Fatalf("building matcher: %v", err)
+			}
+			if got := m.Matches(tt.namespace, tt.labels); got != tt.want {
+				t.Errorf("Matches(%q, %v) = %t, want %t", tt.namespace, tt.labels, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForNetworkPolicyPeer(t *testing.T) {
+	appWeb := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	teamA := &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}
+
+	tests := []struct {
+		name            string
+		peer            networkingv1.NetworkPolicyPeer
+		namespace       string
+		namespaceLabels map[string]string
+		podLabels       map[string]string
+		want            bool
+	}{
+		{"pod selector same namespace", networkingv1.NetworkPolicyPeer{PodSelector: appWeb}, "default", nil, map[string]string{"app": "web"}, true},
+		{"pod selector other namespace", networkingv1.NetworkPolicyPeer{PodSelector: appWeb}, "other", nil, map[string]string{"app": "web"}, false},
+		{"namespace selector any pod", networkingv1.NetworkPolicyPeer{NamespaceSelector: teamA}, "other", map[string]string{"team": "a"}, map[string]string{}, true},
+		{"namespace selector mismatch", networkingv1.NetworkPolicyPeer{NamespaceSelector: teamA}, "other", map[string]string{"team": "b"}, map[string]string{}, false},
+		{"both selectors", networkingv1.NetworkPolicyPeer{PodSelector: appWeb, NamespaceSelector: teamA}, "other", map[string]string{"team": "a"}, map[string]string{"app": "db"}, false},
+		{"empty namespace selector", networkingv1.NetworkPolicyPeer{NamespaceSelector: &metav1.LabelSelector{}}, "other", nil, map[string]string{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, ok, err := ForNetworkPolicyPeer("default", tt.peer)
+			if err != nil || !ok {
+				t.Fatalf("ForNetworkPolicyPeer() = %v, %v", ok, err)
+			}
+			if got := m.Matches(tt.namespace, tt.namespaceLabels, tt.podLabels); got != tt.want {
+				t.Errorf("Matches(%q, %v, %v) = %t, want %t", tt.namespace, tt.namespaceLabels, tt.podLabels, got, tt.want)
+			}
+		})
+	}
+
+	if _, ok, _ := ForNetworkPolicyPeer("default", networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8"}}); ok {
+		t.Errorf("ForNetworkPolicyPeer() selected pods for an ipBlock peer")
+	}
+}
+
+func TestForPodNodes(t *testing.T) {
+	node := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a", Labels: map[string]string{
+			"disktype":                    "ssd",
+			"cpus":                        "8",
+			"topology.kubernetes.io/zone": "zone-a",
+		}},
+		Spec: corev1.NodeSpec{Taints: []corev1.Taint{
+			{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule},
+			{Key: "spot", Effect: corev1.TaintEffectPreferNoSchedule},
+		}},
+	}
+	tolerateGPU := []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+	requiredAffinity := func(terms ...corev1.NodeSelectorTerm) *corev1.Affinity {
+		return &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: terms},
+		}}
+	}
+	expression := func(key string, op corev1.NodeSelectorOperator, values ...string) corev1.NodeSelectorTerm {
+		return corev1.NodeSelectorTerm{MatchExpressions: []corev1.NodeSelectorRequirement{{Key: key, Operator: op, Values: values}}}
+	}
+
+	tests := []struct {
+		name string
+		spec corev1.PodSpec
+		want bool
+	}{
+		{"untolerated taint", corev1.PodSpec{}, false},
+		{"tolerated taint", corev1.PodSpec{Tolerations: tolerateGPU}, true},
+		{"tolerate everything", corev1.PodSpec{Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}}}, true},
+		{"node selector matches", corev1.PodSpec{NodeSelector: map[string]string{"disktype": "ssd"}, Tolerations: tolerateGPU}, true},
+		{"node selector mismatch", corev1.PodSpec{NodeSelector: map[string]string{"disktype": "hdd"}, Tolerations: tolerateGPU}, false},
+		{"affinity terms are ORed", corev1.PodSpec{Affinity: requiredAffinity(expression("disktype", corev1.NodeSelectorOpIn, "hdd"), expression("cpus", corev1.NodeSelectorOpGt, "4")), Tolerations: tolerateGPU}, true},
+		{"affinity mismatch", corev1.PodSpec{Affinity: requiredAffinity(expression("cpus", corev1.NodeSelectorOpLt, "4")), Tolerations: tolerateGPU}, false},
+		{"empty affinity term", corev1.PodSpec{Affinity: requiredAffinity(corev1.NodeSelectorTerm{}), Tolerations: tolerateGPU}, false},
+		{"affinity on node name", corev1.PodSpec{Affinity: requiredAffinity(corev1.NodeSelectorTerm{MatchFields: []corev1.NodeSelectorRequirement{
+			{Key: "metadata.name", Operator: corev1.NodeSelectorOpIn, Values: []string{"node-a"}},
+		}}), Tolerations: tolerateGPU}, true},
+		{"spread over missing topology key", corev1.PodSpec{TopologySpreadConstraints: []corev1.TopologySpreadConstraint{
+			{TopologyKey: "kubernetes.io/hostname", WhenUnsatisfiable: corev1.DoNotSchedule},
+		}, Tolerations: tolerateGPU}, false},
+		{"spread over zones", corev1.PodSpec{TopologySpreadConstraints: []corev1.TopologySpreadConstraint{
+			{TopologyKey: "topology.kubernetes.io/zone", WhenUnsatisfiable: corev1.DoNotSchedule},
+		}, Tolerations: tolerateGPU}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ForPodNodes(&corev1.Pod{Spec: tt.spec})
+			if err != nil {
+				t.Fatalf("ForPodNodes() error = %v", err)
+			}
+			tolerated, got := m.Matches(node)
+			if got != tt.want {
+				t.Errorf("Matches() = %t, want %t", got, tt.want)
+			}
+			if got && len(tolerated) != 1 {
+				t.Errorf("Matches() tolerated %v, want only the NoSchedule taint", tolerated)
+			}
+		})
+	}
+}
+
+func TestIndex(t *testing.T) {
+	key := func(name string) graph.EntityKey {
+		return graph.EntityKey{Name: name, Namespace: "default", Type: "Service"}
+	}
+	tierIn, err := labels.Parse("tier in (frontend,backend)")
+	if err != nil {
+		t.Fatalf("parsing selector: %v", err)
+	}
+	appWeb := labels.SelectorFromSet(labels.Set{"app": "web"})
+	appDB := labels.SelectorFromSet(labels.Set{"app": "db"})
+
+	index := NewIndex()
+	index.Set(key("tiers"), Matcher{Namespace: "default", Selector: tierIn})
+	index.Set(key("web"), Matcher{Namespace: "default", Selector: appWeb})
+	index.Set(key("everything"), Matcher{Namespace: "default", Selector: labels.Everything()})
+	index.Set(key("nothing"), Matcher{Namespace: "default", Selector: labels.Nothing()})
+	index.Set(graph.EntityKey{Name: "web", Namespace: "other", Type: "Service"}, Matcher{Namespace: "other", Selector: appWeb})
+
+	matching := func(namespace string, podLabels map[string]string) []string {
+		var names []string
+		for _, key := range index.Matching(namespace, podLabels) {
+			names = append(names, key.Namespace+"/"+key.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		labels    map[string]string
+		want      []string
+	}{
+		{"in selector first value", "default", map[string]string{"tier": "frontend"}, []string{"default/everything", "default/tiers"}},
+		{"in selector second value", "default", map[string]string{"tier": "backend"}, []string{"default/everything", "default/tiers"}},
+		{"in selector other value", "default", map[string]string{"tier": "cache"}, []string{"default/everything"}},
+		{"several selectors", "default", map[string]string{"app": "web", "tier": "frontend"}, []string{"default/everything", "default/tiers", "default/web"}},
+		{"no labels", "default", nil, []string{"default/everything"}},
+		{"namespace isolation", "other", map[string]string{"app": "web", "tier": "frontend"}, []string{"other/web"}},
+		{"unknown namespace", "missing", map[string]string{"app": "web"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matching(tt.namespace, tt.labels); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Matching(%q, %v) = %v, want %v", tt.namespace, tt.labels, got, tt.want)
+			}
+		})
+	}
+
+	// A selector that matches nothing is never indexed
+	if _, ok := index.indexedAs[key("nothing")]; ok {
+		t.Errorf("Nothing() selector indexed under labels")
+	}
+	if _, ok := index.unindexed["default"][key("nothing")]; ok {
+		t.Errorf("Nothing() selector checked against every pod")
+	}
+	for _, name := range matching("default", map[string]string{"app": "web", "tier": "frontend", "track": "stable"}) {
+		if name == "default/nothing" {
+			t.Errorf("Matching() returned a Nothing() selector")
+		}
+	}
+
+	// Setting a changed selector replaces the old one's index entries
+	index.Set(key("web"), Matcher{Namespace: "default", Selector: appDB})
+	if got, want := matching("default", map[string]string{"app": "web"}), []string{"default/everything"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Matching() after changing the selector = %v, want %v", got, want)
+	}
+	if got, want := matching("default", map[string]string{"app": "db"}), []string{"default/everything", "default/web"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Matching() for the new selector = %v, want %v", got, want)
+	}
+	if _, ok := index.byLabel["default"][labelPair{key: "app", value: "web"}]; ok {
+		t.Errorf("old selector still indexed after Set")
+	}
+
+	// Deleting every key leaves the index empty
+	for _, name := range []string{"tiers", "web", "everything", "nothing"} {
+		index.Delete(key(name))
+	}
+	index.Delete(graph.EntityKey{Name: "web", Namespace: "other", Type: "Service"})
+	if got := matching("default", map[string]string{"app": "db", "tier": "frontend"}); got != nil {
+		t.Errorf("Matching() after deleting every selector = %v, want none", got)
+	}
+	if len(index.matchers) != 0 || len(index.indexedAs) != 0 || len(index.byLabel) != 0 || len(index.unindexed) != 0 {
+		t.Errorf("index not empty after deleting every selector")
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"log"
+	"sync"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	"github.com/AdityaaMK/kubernetes-scraper/k8sclient"
+	"github.com/AdityaaMK/kubernetes-scraper/selector"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+// selectorMatcher returns the key of a selecting object and the matcher for
+// the pods it selects
+type selectorMatcher func(obj interface{}) (graph.EntityKey, selector.Matcher, bool)
+
+// selectorRelationships maintains relationships from objects of one kind to
+// the pods their label selectors match. Selectors are kept in a reverse
+// index, so a pod event only re-evaluates the objects that could select the
+// pod.
+//
+// The relationships to each pod are asserted through SyncRelationships with
+// the pod as their origin. Selector and pod events arrive on separate
+// handler goroutines from the graph's own node changes, so a pod or
+// selecting object removed and added again, whichever handler runs first,
+// gets its relationships back from the graph rather than from an event.
+// Every update holds mu, so an evaluation that read a pod or selector from
+// the cache before it was deleted is always followed by the delete.
+type selectorRelationships struct {
+	g                *graph.Graph
+	informers        *k8sclient.Informers
+	kind             string
+	relationshipType string
+	matcher          selectorMatcher
+	index            *selector.Index
+	mu               sync.Mutex
+
+	// The pods each object selects and the objects selecting each pod
+	pods      map[graph.EntityKey]map[graph.EntityKey]struct{}
+	selectors map[graph.EntityKey]map[graph.EntityKey]struct{}
+}
+
+// newSelectorRelationships creates relationships of relationshipType from
+// objects of kind to the pods they select
+func newSelectorRelationships(g *graph.Graph, informers *k8sclient.Informers, kind, relationshipType string, matcher selectorMatcher) *selectorRelationships {
+	return &selectorRelationships{
+		g:                g,
+		informers:        informers,
+		kind:             kind,
+		relationshipType: relationshipType,
+		matcher:          matcher,
+		index:            selector.NewIndex(),
+		pods:             make(map[graph.EntityKey]map[graph.EntityKey]struct{}),
+		selectors:        make(map[graph.EntityKey]map[graph.EntityKey]struct{}),
+	}
+}
+
+// register adds the event handlers for the selecting kind and for pods
+func (s *selectorRelationships) register() error {
+	deleteSelector := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if key, _, ok := s.matcher(obj); ok {
+			s.deleteSelector(key)
+		}
+	}
+
+	err := s.informers.AddEventHandler(s.kind, cache.ResourceEventHandlerFuncs{
+		AddFunc:    s.updateSelector,
+		UpdateFunc: func(_, newObj interface{}) { s.updateSelector(newObj) },
+		DeleteFunc: deleteSelector,
+	})
+	if err != nil {
+		return err
+	}
+
+	updatePod := func(obj interface{}) {
+		if pod, ok := obj.(*corev1.Pod); ok {
+			s.updatePod(pod)
+		}
+	}
+	deletePod := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		if pod, ok := obj.(*corev1.Pod); ok {
+			s.deletePod(graph.EntityKey{Name: pod.Name, Namespace: pod.Namespace, Type: "Pod"})
+		}
+	}
+
+	return s.informers.AddEventHandler("Pod", cache.ResourceEventHandlerFuncs{
+		AddFunc: updatePod,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			// Label changes move the pod between selectors. Resyncs and
+			// recreations re-assert the relationships, which restores any
+			// the graph dropped along with a removed node.
+			oldPod, ok := oldObj.(*corev1.Pod)
+			newPod, ok2 := newObj.(*corev1.Pod)
+			if ok && ok2 && labels.Equals(oldPod.Labels, newPod.Labels) &&
+				!isResync(oldObj, newObj) && !isRecreated(oldObj, newObj) {
+				return
+			}
+			updatePod(newObj)
+		},
+		DeleteFunc: deletePod,
+	})
+}
+
+// resync evaluates every selector once against the synced pod cache, since
+// selecting objects may have been delivered before the pods they select
+func (s *selectorRelationships) resync() {
+	for _, obj := range s.informers.Indexer(s.kind).List() {
+		s.updateSelector(obj)
+	}
+}
+
+// updateSelector indexes the selector of an object and recomputes its
+// relationships to the pods in its namespace
+func (s *selectorRelationships) updateSelector(obj interface{}) {
+	key, matcher, ok := s.matcher(obj)
+	if !ok {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.index.Set(key, matcher)
+
+	objs, err := s.informers.Indexer("Pod").ByIndex(cache.NamespaceIndex, key.Namespace)
+	if err != nil {
+		log.Printf("Error listing pods for %s %s/%s: %v", s.kind, key.Namespace, key.Name, err)
+		return
+	}
+
+	// Pods the object selected before, including any no longer cached
+	previous := s.pods[key]
+	evaluate := make(map[graph.EntityKey]struct{}, len(previous))
+	for podKey := range previous {
+		evaluate[podKey] = struct{}{}
+	}
+
+	for _, obj := range objs {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok {
+			continue
+		}
+		podKey := graph.EntityKey{Name: pod.Name, Namespace: pod.Namespace, Type: "Pod"}
+		delete(evaluate, podKey)
+
+		if matcher.MatchesPod(pod) {
+			s.link(key, podKey)
+			s.syncPod(podKey)
+		} else if _, ok := previous[podKey]; ok {
+			s.unlink(key, podKey)
+			s.syncPod(podKey)
+		}
+	}
+
+	for podKey := range evaluate {
+		s.unlink(key, podKey)
+		s.syncPod(podKey)
+	}
+}
+
+// updatePod recomputes the relationships to a pod. Only the objects whose
+// selectors match the pod's labels, and the objects that selected it
+// before, are evaluated.
+func (s *selectorRelationships) updatePod(pod *corev1.Pod) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	podKey := graph.EntityKey{Name: pod.Name, Namespace: pod.Namespace, Type: "Pod"}
+
+	matching := make(map[graph.EntityKey]struct{})
+	for _, key := range s.index.Matching(pod.Namespace, pod.Labels) {
+		matching[key] = struct{}{}
+		s.link(key, podKey)
+	}
+
+	// Forget the objects that no longer select the pod
+	for key := range s.selectors[podKey] {
+		if _, ok := matching[key]; !ok {
+			s.unlink(key, podKey)
+		}
+	}
+
+	s.syncPod(podKey)
+}
+
+// deleteSelector removes a deleted object from the index along with its relationships
+func (s *selectorRelationships) deleteSelector(key graph.EntityKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.index.Delete(key)
+	for podKey := range s.pods[key] {
+		s.unlink(key, podKey)
+		s.syncPod(podKey)
+	}
+}
+
+// deletePod removes the relationships to a deleted pod
+func (s *selectorRelationships) deletePod(podKey graph.EntityKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key := range s.selectors[podKey] {
+		s.unlink(key, podKey)
+	}
+	s.syncPod(podKey)
+}
+
+// link records that the object key selects a pod.
+// The caller must hold mu.
+func (s *selectorRelationships) link(key, podKey graph.EntityKey) {
+	addToSet(s.pods, key, podKey)
+	addToSet(s.selectors, podKey, key)
+}
+
+// unlink records that the object key no longer selects a pod.
+// The caller must hold mu.
+func (s *selectorRelationships) unlink(key, podKey graph.EntityKey) {
+	removeFromSet(s.pods, key, podKey)
+	removeFromSet(s.selectors, podKey, key)
+}
+
+// syncPod asserts the relationships from the objects selecting a pod.
+// The caller must hold mu.
+func (s *selectorRelationships) syncPod(podKey graph.EntityKey) {
+	rels := make([]graph.GraphRelationship, 0, len(s.selectors[podKey]))
+	for key := range s.selectors[podKey] {
+		rels = append(rels, graph.GraphRelationship{
+			Source:           key,
+			Target:           podKey,
+			RelationshipType: s.relationshipType,
+		})
+	}
+	s.g.SyncRelationships("selector:"+s.kind+":"+podKey.String(), rels)
+}
+
+// addToSet adds value to the set stored under key
+func addToSet(sets map[graph.EntityKey]map[graph.EntityKey]struct{}, key, value graph.EntityKey) {
+	set, ok := sets[key]
+	if !ok {
+		set = make(map[graph.EntityKey]struct{})
+		sets[key] = set
+	}
+	set[value] = struct{}{}
+}
+
+// removeFromSet removes value from the set stored under key, and the set once it is empty
+func removeFromSet(sets map[graph.EntityKey]map[graph.EntityKey]struct{}, key, value graph.EntityKey) {
+	set := sets[key]
+	delete(set, value)
+	if len(set) == 0 {
+		delete(sets, key)
+	}
+}
+
+// serviceSelector matches the pods a Service targets
+func serviceSelector(obj interface{}) (graph.EntityKey, selector.Matcher, bool) {
+	service, ok := obj.(*corev1.Service)
+	if !ok {
+		return graph.EntityKey{}, selector.Matcher{}, false
+	}
+	key := graph.EntityKey{Name: service.Name, Namespace: service.Namespace, Type: "Service"}
+	return key, selector.ForService(service), true
+}
+
+// podDisruptionBudgetSelector matches the pods a PodDisruptionBudget protects
+func podDisruptionBudgetSelector(obj interface{}) (graph.EntityKey, selector.Matcher, bool) {
+	pdb, ok := obj.(*policyv1.PodDisruptionBudget)
+	if !ok {
+		return graph.EntityKey{}, selector.Matcher{}, false
+	}
+	key := graph.EntityKey{Name: pdb.Name, Namespace: pdb.Namespace, Type: "PodDisruptionBudget"}
+	matcher, err := selector.ForPodDisruptionBudget(pdb)
+	if err != nil {
+		log.Printf("Invalid selector on PodDisruptionBudget %s/%s: %v", pdb.Namespace, pdb.Name, err)
+		return key, selector.Matcher{Namespace: pdb.Namespace, Selector: labels.Nothing()}, true
+	}
+	return key, matcher, true
+}
+
+// networkPolicySelector matches the pods a NetworkPolicy applies to
+func networkPolicySelector(obj interface{}) (graph.EntityKey, selector.Matcher, bool) {
+	policy, ok := obj.(*networkingv1.NetworkPolicy)
+	if !ok {
+		return graph.EntityKey{}, selector.Matcher{}, false
+	}
+	key := graph.EntityKey{Name: policy.Name, Namespace: policy.Namespace, Type: "NetworkPolicy"}
+	matcher, err := selector.ForNetworkPolicy(policy)
+	if err != nil {
+		log.Printf("Invalid podSelector on NetworkPolicy %s/%s: %v", policy.Namespace, policy.Name, err)
+		return key, selector.Matcher{Namespace: policy.Namespace, Selector: labels.Nothing()}, true
+	}
+	return key, matcher, true
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	"github.com/AdityaaMK/kubernetes-scraper/k8sclient"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// newTestInformers creates informers over fake clientsets. They are never
+// started; tests fill their caches directly.
+func newTestInformers(t *testing.T) *k8sclient.Informers {
+	t.Helper()
+	client := k8sclient.NewK8sClientForClientsets(fake.NewSimpleClientset(), dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()))
+	informers, err := client.NewInformers(0, nil)
+	if err != nil {
+		t.Fatalf("NewInformers: %v", err)
+	}
+	return informers
+}
+
+// addToCache adds objects to the informer cache of their resource type
+func addToCache(t *testing.T, informers *k8sclient.Informers, resourceType string, objs ...interface{}) {
+	t.Helper()
+	for _, obj := range objs {
+		if err := informers.Indexer(resourceType).Add(obj); err != nil {
+			t.Fatalf("adding %s to cache: %v", resourceType, err)
+		}
+	}
+}
+
+// deleteFromCache removes objects from the informer cache of their resource type
+func deleteFromCache(t *testing.T, informers *k8sclient.Informers, resourceType string, objs ...interface{}) {
+	t.Helper()
+	for _, obj := range objs {
+		if err := informers.Indexer(resourceType).Delete(obj); err != nil {
+			t.Fatalf("deleting %s from cache: %v", resourceType, err)
+		}
+	}
+}
+
+// hasRelationship reports whether the graph holds a relationship
+func hasRelationship(g *graph.Graph, source, target graph.EntityKey, relationshipType string) bool {
+	for _, rel := range g.Outgoing(source) {
+		if rel.Target == target && rel.RelationshipType == relationshipType {
+			return true
+		}
+	}
+	return false
+}
+
+func testPod(name, uid string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:            name,
+		Namespace:       "default",
+		UID:             types.UID(uid),
+		ResourceVersion: uid,
+		Labels:          labels,
+	}}
+}
+
+func testPodDisruptionBudget(name, uid string, matchLabels map[string]string) *policyv1.PodDisruptionBudget {
+	return &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID(uid), ResourceVersion: uid},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: matchLabels},
+		},
+	}
+}
+
+func TestSelectorRelationships(t *testing.T) {
+	pdbKey := graph.EntityKey{Name: "web", Namespace: "default", Type: "PodDisruptionBudget"}
+	podKey := graph.EntityKey{Name: "web-0", Namespace: "default", Type: "Pod"}
+	otherKey := graph.EntityKey{Name: "db-0", Namespace: "default", Type: "Pod"}
+
+	informers := newTestInformers(t)
+	g := graph.NewGraph()
+	s := newSelectorRelationships(g, informers, "PodDisruptionBudget", "protects", podDisruptionBudgetSelector)
+
+	pdb := testPodDisruptionBudget("web", "pdb-1", map[string]string{"app": "web"})
+	pod := testPod("web-0", "pod-1", map[string]string{"app": "web"})
+	other := testPod("db-0", "pod-2", map[string]string{"app": "db"})
+	addToCache(t, informers, "Pod", pod, other)
+	addToCache(t, informers, "PodDisruptionBudget", pdb)
+
+	s.updateSelector(pdb)
+	if !hasRelationship(g, pdbKey, podKey, "protects") {
+		t.Fatalf("selector update: missing protects relationship to %s", podKey)
+	}
+	if hasRelationship(g, pdbKey, otherKey, "protects") {
+		t.Fatalf("selector update: unexpected protects relationship to %s", otherKey)
+	}
+
+	// Relabeling moves the pods between selectors
+	relabeled := testPod("db-0", "pod-2", map[string]string{"app": "web"})
+	addToCache(t, informers, "Pod", relabeled)
+	s.updatePod(relabeled)
+	if !hasRelationship(g, pdbKey, otherKey, "protects") {
+		t.Fatalf("pod update: missing protects relationship to relabeled %s", otherKey)
+	}
+
+	// A selector change removes the relationships to pods it no longer matches
+	narrowed := testPodDisruptionBudget("web", "pdb-1", map[string]string{"app": "web", "tier": "front"})
+	addToCache(t, informers, "PodDisruptionBudget", narrowed)
+	s.updateSelector(narrowed)
+	if got := g.Incoming(podKey); len(got) != 0 {
+		t.Fatalf("narrowed selector: got relationships %v, want none", got)
+	}
+
+	s.updateSelector(pdb)
+	deleteFromCache(t, informers, "Pod", relabeled)
+	s.deletePod(otherKey)
+	if hasRelationship(g, pdbKey, otherKey, "protects") {
+		t.Fatalf("pod delete: protects relationship to %s remains", otherKey)
+	}
+
+	deleteFromCache(t, informers, "PodDisruptionBudget", pdb)
+	s.deleteSelector(pdbKey)
+	if got := g.Outgoing(pdbKey); len(got) != 0 {
+		t.Fatalf("selector delete: got relationships %v, want none", got)
+	}
+}
+
+// TestSelectorRelationshipsSurviveRecreation recreates a pod and a selecting
+// object under the same names, with the graph handler removing the old
+// incarnation after the selector relationships were evaluated
+func TestSelectorRelationshipsSurviveRecreation(t *testing.T) {
+	pdbKey := graph.EntityKey{Name: "web", Namespace: "default", Type: "PodDisruptionBudget"}
+	podKey := graph.EntityKey{Name: "web-0", Namespace: "default", Type: "Pod"}
+
+	for _, policy := range []graph.DanglingPolicy{graph.DanglingKeep, graph.DanglingDrop, graph.DanglingDefer} {
+		t.Run(policy.String(), func(t *testing.T) {
+			informers := newTestInformers(t)
+			g := graph.NewGraph(graph.WithDanglingPolicy(policy))
+			s := newSelectorRelationships(g, informers, "PodDisruptionBudget", "protects", podDisruptionBudgetSelector)
+			podHandler := graphEventHandler(g, "Pod")
+			pdbHandler := graphEventHandler(g, "PodDisruptionBudget")
+
+			pdb := testPodDisruptionBudget("web", "pdb-1", map[string]string{"app": "web"})
+			pod := testPod("web-0", "pod-1", map[string]string{"app": "web"})
+			addToCache(t, informers, "Pod", pod)
+			addToCache(t, informers, "PodDisruptionBudget", pdb)
+			pdbHandler.OnAdd(pdb, false)
+			podHandler.OnAdd(pod, false)
+			s.updateSelector(pdb)
+			if !hasRelationship(g, pdbKey, podKey, "protects") {
+				t.Fatalf("initial: missing protects relationship")
+			}
+
+			// Pod recreated with the same labels
+			recreated := testPod("web-0", "pod-3", map[string]string{"app": "web"})
+			addToCache(t, informers, "Pod", recreated)
+			s.updatePod(recreated)
+			podHandler.OnUpdate(pod, recreated)
+			if policy == graph.DanglingDrop {
+				// Dropped with the old incarnation, and restored by the next resync
+				if hasRelationship(g, pdbKey, podKey, "protects") {
+					t.Fatalf("recreated pod: protects relationship kept under drop")
+				}
+				s.updatePod(recreated)
+			}
+			if !hasRelationship(g, pdbKey, podKey, "protects") {
+				t.Fatalf("recreated pod: missing protects relationship")
+			}
+
+			// Selecting object recreated
+			recreatedPDB := testPodDisruptionBudget("web", "pdb-2", map[string]string{"app": "web"})
+			addToCache(t, informers, "PodDisruptionBudget", recreatedPDB)
+			s.updateSelector(recreatedPDB)
+			pdbHandler.OnUpdate(pdb, recreatedPDB)
+			if policy == graph.DanglingDrop {
+				s.updateSelector(recreatedPDB)
+			}
+			if !hasRelationship(g, pdbKey, podKey, "protects") {
+				t.Fatalf("recreated PodDisruptionBudget: missing protects relationship")
+			}
+		})
+	}
+}
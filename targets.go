@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	"github.com/AdityaaMK/kubernetes-scraper/k8sclient"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Ways of computing Service -> Pod targets relationships
+const (
+	targetsFromEndpointSlices = "endpointslices"
+	targetsFromLabels         = "labels"
+)
+
+// serviceTargets maintains Service -> Pod targets relationships
+type serviceTargets interface {
+	// register adds the informer event handlers that keep relationships current
+	register() error
+	// resync recomputes relationships for every Service from the synced caches
+	resync()
+}
+
+// newServiceTargets returns the serviceTargets implementation for a mode
+func newServiceTargets(mode string, g *graph.Graph, informers *k8sclient.Informers) (serviceTargets, error) {
+	switch mode {
+	case targetsFromEndpointSlices:
+		return &endpointSliceTargets{g: g, informers: informers}, nil
+	case targetsFromLabels:
+		// Match Service selectors against pod labels. This is the fallback
+		// for clusters without EndpointSlices.
+		return newSelectorRelationships(g, informers, "Service", "targets", serviceSelector), nil
+	default:
+		return nil, fmt.Errorf("unknown service targets mode %q", mode)
+	}
+}
+
+// endpointSliceTargets derives targets relationships from the EndpointSlices
+// of each Service, so they reflect the endpoints the Service actually routes
+// to, including Services without a selector
+type endpointSliceTargets struct {
+	g         *graph.Graph
+	informers *k8sclient.Informers
+
+	// mu serializes recomputing Services from event handlers and resync
+	mu sync.Mutex
+}
+
+// endpointTarget accumulates the state of one pod across a Service's EndpointSlices
+type endpointTarget struct {
+	pod         graph.EntityKey
+	ready       bool
+	serving     bool
+	terminating bool
+	ports       map[string]struct{}
+}
+
+func (t *endpointSliceTargets) register() error {
+	syncSlice := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		slice, ok := obj.(*discoveryv1.EndpointSlice)
+		if !ok {
+			return
+		}
+		if serviceName := slice.Labels[discoveryv1.LabelServiceName]; serviceName != "" {
+			t.syncService(slice.Namespace, serviceName)
+		}
+	}
+
+	return t.informers.AddEventHandler("EndpointSlice", cache.ResourceEventHandlerFuncs{
+		AddFunc:    syncSlice,
+		UpdateFunc: func(_, newObj interface{}) { syncSlice(newObj) },
+		DeleteFunc: syncSlice,
+	})
+}
+
+func (t *endpointSliceTargets) resync() {
+	for _, obj := range t.informers.Indexer("Service").List() {
+		if service, ok := obj.(*corev1.Service); ok {
+			t.syncService(service.Namespace, service.Name)
+		}
+	}
+}
+
+// syncService recomputes the targets relationships of a Service from all of
+// its EndpointSlices, removing relationships to pods no longer listed
+func (t *endpointSliceTargets) syncService(namespace, name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	serviceKey := graph.EntityKey{Name: name, Namespace: namespace, Type: "Service"}
+
+	objs, err := t.informers.Indexer("EndpointSlice").ByIndex(cache.NamespaceIndex, namespace)
+	if err != nil {
+		log.Printf("Error listing EndpointSlices for Service %s/%s: %v", namespace, name, err)
+		return
+	}
+
+	targets := make(map[graph.EntityKey]*endpointTarget)
+	for _, obj := range objs {
+		slice, ok := obj.(*discoveryv1.EndpointSlice)
+		if !ok || slice.Labels[discoveryv1.LabelServiceName] != name {
+			continue
+		}
+
+		ports := endpointPorts(slice.Ports)
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.TargetRef == nil || endpoint.TargetRef.Kind != "Pod" {
+				continue
+			}
+			podNamespace := endpoint.TargetRef.Namespace
+			if podNamespace == "" {
+				podNamespace = namespace
+			}
+			pod := graph.EntityKey{Name: endpoint.TargetRef.Name, Namespace: podNamespace, Type: "Pod"}
+
+			// A pod can appear in several slices, e.g. one per address
+			// family, so merge its conditions and ports
+			target, ok := targets[pod]
+			if !ok {
+				target = &endpointTarget{pod: pod, ports: make(map[string]struct{})}
+				targets[pod] = target
+			}
+
+			// Unset ready and serving conditions mean ready; unset terminating means not terminating
+			ready := endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready
+			serving := ready
+			if endpoint.Conditions.Serving != nil {
+				serving = *endpoint.Conditions.Serving
+			}
+			terminating := endpoint.Conditions.Terminating != nil && *endpoint.Conditions.Terminating
+
+			target.ready = target.ready || ready
+			target.serving = target.serving || serving
+			target.terminating = target.terminating || terminating
+			for _, port := range ports {
+				target.ports[port] = struct{}{}
+			}
+		}
+	}
+
+	rels := make([]graph.GraphRelationship, 0, len(targets))
+	for _, target := range targets {
+		ports := make([]string, 0, len(target.ports))
+		for port := range target.ports {
+			ports = append(ports, port)
+		}
+		sort.Strings(ports)
+
+		rels = append(rels, graph.GraphRelationship{
+			Source:           serviceKey,
+			Target:           target.pod,
+			RelationshipType: "targets",
+			Properties: map[string]string{
+				"ready":       fmt.Sprintf("%t", target.ready),
+				"serving":     fmt.Sprintf("%t", target.serving),
+				"terminating": fmt.Sprintf("%t", target.terminating),
+				"ports":       strings.Join(ports, ","),
+			},
+		})
+	}
+
+	t.g.SyncRelationships("endpointslices:"+serviceKey.String(), rels)
+}
+
+// endpointPorts formats EndpointSlice ports as [name:]port/protocol
+func endpointPorts(ports []discoveryv1.EndpointPort) []string {
+	formatted := make([]string, 0, len(ports))
+	for _, port := range ports {
+		if port.Port == nil {
+			continue
+		}
+		protocol := corev1.ProtocolTCP
+		if port.Protocol != nil {
+			protocol = *port.Protocol
+		}
+		value := fmt.Sprintf("%d/%s", *port.Port, protocol)
+		if port.Name != nil && *port.Name != "" {
+			value = *port.Name + ":" + value
+		}
+		formatted = append(formatted, value)
+	}
+	return formatted
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/AdityaaMK/kubernetes-scraper/graph"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testEndpointSlice(name, serviceName string, ports []discoveryv1.EndpointPort, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: serviceName},
+		},
+		Ports:     ports,
+		Endpoints: endpoints,
+	}
+}
+
+func testEndpoint(pod string, ready, serving, terminating *bool) discoveryv1.Endpoint {
+	return discoveryv1.Endpoint{
+		Conditions: discoveryv1.EndpointConditions{Ready: ready, Serving: serving, Terminating: terminating},
+		TargetRef:  &corev1.ObjectReference{Kind: "Pod", Name: pod, Namespace: "default"},
+	}
+}
+
+func testEndpointPort(name string, port int32, protocol corev1.Protocol) discoveryv1.EndpointPort {
+	return discoveryv1.EndpointPort{Name: &name, Port: &port, Protocol: &protocol}
+}
+
+func TestEndpointSliceTargets(t *testing.T) {
+	yes, no := true, false
+	service := graph.EntityKey{Name: "web", Namespace: "default", Type: "Service"}
+	web0 := graph.EntityKey{Name: "web-0", Namespace: "default", Type: "Pod"}
+	web1 := graph.EntityKey{Name: "web-1", Namespace: "default", Type: "Pod"}
+
+	// web-0 is listed in both slices, which disagree on its conditions and ports
+	ipv4 := testEndpointSlice("web-ipv4", "web",
+		[]discoveryv1.EndpointPort{testEndpointPort("http", 80, corev1.ProtocolTCP)},
+		testEndpoint("web-0", &no, &yes, &yes),
+		testEndpoint("web-1", nil, nil, nil),
+		discoveryv1.Endpoint{Addresses: []string{"10.0.0.9"}},
+	)
+	ipv6 := testEndpointSlice("web-ipv6", "web",
+		[]discoveryv1.EndpointPort{testEndpointPort("metrics", 9090, corev1.ProtocolTCP), testEndpointPort("", 53, corev1.ProtocolUDP)},
+		testEndpoint("web-0", &yes, &no, &no),
+	)
+	other := testEndpointSlice("db", "db", nil, testEndpoint("web-1", &no, &no, &no))
+
+	tests := []struct {
+		name   string
+		slices []*discoveryv1.EndpointSlice
+		want   map[graph.EntityKey]map[string]string
+	}{
+		{
+			name:   "conditions and ports merged across slices",
+			slices: []*discoveryv1.EndpointSlice{ipv4, ipv6, other},
+			want: map[graph.EntityKey]map[string]string{
+				web0: {"ready": "true", "serving": "true", "terminating": "true", "ports": "53/UDP,http:80/TCP,metrics:9090/TCP"},
+				web1: {"ready": "true", "serving": "true", "terminating": "false", "ports": "http:80/TCP"},
+			},
+		},
+		{
+			name:   "single slice",
+			slices: []*discoveryv1.EndpointSlice{ipv6},
+			want: map[graph.EntityKey]map[string]string{
+				web0: {"ready": "true", "serving": "false", "terminating": "false", "ports": "53/UDP,metrics:9090/TCP"},
+			},
+		},
+		{
+			name:   "unset conditions",
+			slices: []*discoveryv1.EndpointSlice{testEndpointSlice("web-ipv4", "web", nil, testEndpoint("web-0", nil, nil, nil))},
+			want: map[graph.EntityKey]map[string]string{
+				web0: {"ready": "true", "serving": "true", "terminating": "false", "ports": ""},
+			},
+		},
+		{
+			name:   "not ready but serving while terminating",
+			slices: []*discoveryv1.EndpointSlice{testEndpointSlice("web-ipv4", "web", nil, testEndpoint("web-0", &no, &yes, &yes))},
+			want: map[graph.EntityKey]map[string]string{
+				web0: {"ready": "false", "serving": "true", "terminating": "true", "ports": ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			informers := newTestInformers(t)
+			g := graph.NewGraph()
+			targets := &endpointSliceTargets{g: g, informers: informers}
+
+			for _, slice := range tt.slices {
+				addToCache(t, informers, "EndpointSlice", slice)
+			}
+			targets.syncService("default", "web")
+
+			got := make(map[graph.EntityKey]map[string]string)
+			for _, rel := range g.Outgoing(service) {
+				if rel.RelationshipType != "targets" {
+					t.Errorf("unexpected %s relationship to %s", rel.RelationshipType, rel.Target)
+				}
+				got[rel.Target] = rel.Properties
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("targets = %v, want %v", got, tt.want)
+			}
+
+			// Removing every slice removes the relationships
+			for _, slice := range tt.slices {
+				deleteFromCache(t, informers, "EndpointSlice", slice)
+			}
+			targets.syncService("default", "web")
+			if rels := g.Outgoing(service); len(rels) != 0 {
+				t.Errorf("targets after slices deleted = %v, want none", rels)
+			}
+		})
+	}
+}